@@ -0,0 +1,288 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/marianozunino/go-publish/internal/amqp10"
+	"github.com/marianozunino/go-publish/internal/broker"
+	"github.com/marianozunino/go-publish/internal/jetstream"
+	"github.com/marianozunino/go-publish/internal/kafka"
+	"github.com/marianozunino/go-publish/internal/mqtt"
+	"github.com/marianozunino/go-publish/internal/ordering"
+	"github.com/marianozunino/go-publish/internal/publisher"
+	"github.com/marianozunino/go-publish/internal/redisstream"
+	"github.com/marianozunino/go-publish/internal/sink"
+	"github.com/marianozunino/go-publish/internal/webhook"
+)
+
+// connect picks the backend from the scheme of the URIs and connects to the
+// first reachable node. The returned connector lets the UI fail over to the
+// next node when the connection is lost.
+func connect(uris []string, destination string) (publisher.Connector, publisher.Publisher, error) {
+	connector, err := newConnector(uris, destination)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conn, err := connector.Connect()
+	if err != nil {
+		return nil, nil, err
+	}
+	return connector, conn, nil
+}
+
+// newConnector returns the connector of the backend handling the URIs,
+// which must all use the same scheme
+func newConnector(uris []string, destination string) (publisher.Connector, error) {
+	if len(uris) == 0 {
+		return nil, fmt.Errorf("no URI given")
+	}
+	if writesToStdout(uris) {
+		return newSinkConnector(uris)
+	}
+	scheme, err := publisher.Scheme(uris[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, uri := range uris[1:] {
+		other, err := publisher.Scheme(uri)
+		if err != nil {
+			return nil, err
+		}
+		if backendOf(other) != backendOf(scheme) {
+			return nil, fmt.Errorf("cannot mix %s:// and %s:// URIs", scheme, other)
+		}
+	}
+
+	switch backendOf(scheme) {
+	case "amqp":
+		return newAMQPConnector(uris, destination)
+	case "amqp10":
+		return newAMQP10Connector(uris, destination)
+	case "kafka":
+		return newKafkaConnector(uris, destination)
+	case "nats":
+		return newNATSConnector(uris, destination)
+	case "redis":
+		return newRedisConnector(uris, destination)
+	case "mqtt":
+		return newMQTTConnector(uris, destination)
+	case "http":
+		return newWebhookConnector(uris)
+	case "file":
+		return newSinkConnector(uris)
+	default:
+		return nil, fmt.Errorf("unsupported URI scheme %q", scheme)
+	}
+}
+
+// backendOf maps a URI scheme to the backend handling it, so that the plain
+// and TLS schemes of one backend can be mixed
+func backendOf(scheme string) string {
+	switch scheme {
+	case "amqp", "amqps":
+		return "amqp"
+	case "amqp10", "amqp10s":
+		return "amqp10"
+	case "kafka", "kafkas":
+		return "kafka"
+	case "redis", "rediss":
+		return "redis"
+	case "mqtt", "mqtts":
+		return "mqtt"
+	case "http", "https":
+		return "http"
+	default:
+		return scheme
+	}
+}
+
+// newAMQPConnector connects to RabbitMQ, with one channel per worker
+func newAMQPConnector(uris []string, queueName string) (publisher.Connector, error) {
+	dialer, err := broker.NewDialer(broker.Options{
+		URIs:   uris,
+		Random: nodeOrder == "random",
+		Vhost:  vhost,
+		TLS:    tlsOptions(),
+		Connection: broker.ConnectionOptions{
+			Name:       connectionName,
+			Version:    VersionFromBuild(),
+			Heartbeat:  heartbeat,
+			ChannelMax: channelMax,
+			FrameSize:  frameSize,
+			Locale:     locale,
+		},
+		QueueName:     queueName,
+		Declare:       broker.DeclareMode(declareMode),
+		QueueType:     queueType,
+		QueueArgs:     parseQueueArgs(queueArgs),
+		ManagementURL: managementURL,
+		Confirm:       confirm,
+		Workers:       workers,
+		Connections:   connections,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dialer, nil
+}
+
+// newAMQP10Connector sends to an address of an AMQP 1.0 broker, with one
+// link per worker
+func newAMQP10Connector(uris []string, address string) (publisher.Connector, error) {
+	dialer, err := amqp10.NewDialer(amqp10.Options{
+		URIs:    uris,
+		TLS:     tlsOptions(),
+		Name:    connectionName,
+		Version: VersionFromBuild(),
+		Address: address,
+		Confirm: confirm,
+		Workers: workers,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dialer, nil
+}
+
+// newKafkaConnector produces to a Kafka topic, with the seed brokers taken
+// from the URIs
+func newKafkaConnector(uris []string, topic string) (publisher.Connector, error) {
+	key, err := ordering.ParseKey(kafkaKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --kafka-key: %w", err)
+	}
+
+	// Idempotence is on by default, but only possible with acks=all; give
+	// way to a weaker acks setting unless asked for explicitly
+	idempotent := kafkaIdempotent
+	if _, explicit := settingSources["kafka-idempotent"]; !explicit && kafkaAcks != "all" {
+		idempotent = false
+	}
+
+	dialer, err := kafka.NewDialer(kafka.Options{
+		URIs:       uris,
+		TLS:        tlsOptions(),
+		ClientID:   connectionName,
+		Topic:      topic,
+		Acks:       kafkaAcks,
+		Idempotent: idempotent,
+		Key:        key,
+		Workers:    workers,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dialer, nil
+}
+
+// newNATSConnector publishes to NATS JetStream. The subject is the queue when
+// one was set and otherwise the routing key of each message.
+func newNATSConnector(uris []string, queueName string) (publisher.Connector, error) {
+	var subject string
+	if _, explicit := settingSources["queue"]; explicit {
+		subject = queueName
+	}
+
+	dialer, err := jetstream.NewDialer(jetstream.Options{
+		URIs:    uris,
+		TLS:     tlsOptions(),
+		Name:    connectionName,
+		Subject: subject,
+		Workers: workers,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dialer, nil
+}
+
+// newRedisConnector adds messages to a Redis stream named by the queue
+func newRedisConnector(uris []string, stream string) (publisher.Connector, error) {
+	dialer, err := redisstream.NewDialer(redisstream.Options{
+		URIs:    uris,
+		TLS:     tlsOptions(),
+		Name:    connectionName,
+		Stream:  stream,
+		MaxLen:  redisMaxLen,
+		Workers: workers,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dialer, nil
+}
+
+// newMQTTConnector publishes to an MQTT 5 server. The topic is the queue when
+// one was set and otherwise the routing key of each message, with dots
+// turned into slashes.
+func newMQTTConnector(uris []string, queueName string) (publisher.Connector, error) {
+	if mqttQoS < 0 || mqttQoS > 2 {
+		return nil, fmt.Errorf("invalid --mqtt-qos %d (expected 0, 1 or 2)", mqttQoS)
+	}
+	var topic string
+	if _, explicit := settingSources["queue"]; explicit {
+		topic = queueName
+	}
+
+	dialer, err := mqtt.NewDialer(mqtt.Options{
+		URIs:    uris,
+		TLS:     tlsOptions(),
+		Topic:   topic,
+		QoS:     byte(mqttQoS),
+		Retain:  mqttRetain,
+		Workers: workers,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dialer, nil
+}
+
+// newWebhookConnector posts messages to the HTTP endpoint given as the URI
+func newWebhookConnector(uris []string) (publisher.Connector, error) {
+	if len(uris) > 1 {
+		return nil, fmt.Errorf("an HTTP target takes a single URL")
+	}
+
+	dialer, err := webhook.NewDialer(webhook.Options{
+		URL:          uris[0],
+		TLS:          tlsOptions(),
+		UserAgent:    "go-publish/" + VersionFromBuild(),
+		Headers:      httpHeaders,
+		HeaderPrefix: httpHeaderPrefix,
+		Retries:      httpRetries,
+		Workers:      workers,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dialer, nil
+}
+
+// newSinkConnector writes messages to a file or stdout instead of publishing
+func newSinkConnector(uris []string) (publisher.Connector, error) {
+	if len(uris) > 1 {
+		return nil, fmt.Errorf("only one output file can be written")
+	}
+
+	dialer, err := sink.NewDialer(sink.Options{
+		URI:    uris[0],
+		Format: sink.Format(sinkFormat),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dialer, nil
+}
+
+// writesToStdout reports whether the messages are written to stdout, which
+// leaves it to the output alone: the UI and status lines go to stderr
+func writesToStdout(uris []string) bool {
+	for _, uri := range uris {
+		if uri == sink.Stdout {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,254 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/marianozunino/go-publish/internal/broker"
+	"github.com/marianozunino/go-publish/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	configFile  string
+	profileName string
+
+	// settingSources records where each configurable flag got its value
+	settingSources = map[string]settingSource{}
+
+	// protectedTarget is set when the selected profile or one of the URIs is
+	// marked as protected in the config file
+	protectedTarget bool
+)
+
+// settingSource is where a setting came from, in increasing precedence
+type settingSource int
+
+const (
+	sourceDefault settingSource = iota
+	sourceProfile
+	sourceEnv
+	sourceFlag
+)
+
+// loadConfig reads the config file given by --config, or the default one
+func loadConfig() (*config.Config, error) {
+	path := configFile
+	if path == "" {
+		path = os.Getenv("GO_PUBLISH_CONFIG")
+	}
+	if path == "" {
+		var err error
+		if path, err = config.DefaultPath(); err != nil {
+			return nil, err
+		}
+	}
+	return config.Load(path)
+}
+
+// applySettings fills in every flag the user did not set on the command line,
+// first from its GO_PUBLISH_* environment variable and then from the selected
+// profile, so the precedence is flags > env vars > profile > defaults. The
+// credentials are then resolved into the URI.
+func applySettings(cmd *cobra.Command) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	name := profileName
+	if name == "" {
+		name = os.Getenv("GO_PUBLISH_PROFILE")
+	}
+	profile, _, err := cfg.Profile(name)
+	if err != nil {
+		return err
+	}
+	fromProfile := profileFlagValues(profile)
+
+	flags := cmd.Flags()
+	for _, flagName := range configurableFlags {
+		flag := flags.Lookup(flagName)
+		if flag == nil {
+			continue
+		}
+		if flag.Changed {
+			settingSources[flagName] = sourceFlag
+			continue
+		}
+
+		source := sourceEnv
+		value, ok := os.LookupEnv(envVarName(flagName))
+		if !ok {
+			source = sourceProfile
+			value, ok = fromProfile[flagName]
+		}
+		if !ok {
+			continue
+		}
+
+		if err := flags.Set(flagName, value); err != nil {
+			return fmt.Errorf("invalid value %q for %s: %w", value, flagName, err)
+		}
+		settingSources[flagName] = source
+	}
+
+	if nodeOrder != "ordered" && nodeOrder != "random" {
+		return fmt.Errorf("invalid node order %q (expected ordered or random)", nodeOrder)
+	}
+
+	switch broker.DeclareMode(declareMode) {
+	case broker.DeclareQueue, broker.DeclarePassive, broker.DeclareSkip:
+	default:
+		return fmt.Errorf("invalid declare mode %q (expected declare, passive or skip)", declareMode)
+	}
+	switch queueType {
+	case "", "classic", "quorum", "stream":
+	default:
+		return fmt.Errorf("invalid queue type %q (expected classic, quorum or stream)", queueType)
+	}
+
+	if connectionName == "" {
+		connectionName = defaultConnectionName()
+	}
+
+	protectedTarget = profile.Protected
+	for _, uri := range amqpURIs {
+		if cfg.IsProtected(uri) {
+			protectedTarget = true
+		}
+	}
+
+	uris, err := resolveCredentials(amqpURIs)
+	if err != nil {
+		return err
+	}
+	amqpURIs = uris
+	return nil
+}
+
+// configurableFlags are the flags that can also come from the environment or a profile
+var configurableFlags = []string{
+	"uri",
+	"node-order",
+	"user",
+	"password-file",
+	"password-command",
+	"vhost",
+	"queue",
+	"declare",
+	"queue-type",
+	"queue-arg",
+	"management-url",
+	"connection-name",
+	"heartbeat",
+	"channel-max",
+	"frame-size",
+	"locale",
+	"kafka-acks",
+	"kafka-idempotent",
+	"kafka-key",
+	"redis-maxlen",
+	"mqtt-qos",
+	"mqtt-retain",
+	"http-header",
+	"http-header-prefix",
+	"http-retries",
+	"sink-format",
+	"delay",
+	"insecure",
+	"tls-ca",
+	"tls-cert",
+	"tls-key",
+	"tls-server-name",
+	"tls-min-version",
+}
+
+// envVarName returns the environment variable for a flag, e.g. GO_PUBLISH_TLS_CA
+func envVarName(flagName string) string {
+	return "GO_PUBLISH_" + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// profileFlagValues maps the settings present in a profile to flag values
+func profileFlagValues(p config.Profile) map[string]string {
+	values := map[string]string{}
+	set := func(flagName, value string) {
+		if value != "" {
+			values[flagName] = value
+		}
+	}
+
+	set("uri", p.URI)
+	if len(p.URIs) > 0 {
+		set("uri", strings.Join(p.URIs, ","))
+	}
+	set("node-order", p.NodeOrder)
+	set("user", p.User)
+	set("password-file", p.PasswordFile)
+	set("password-command", p.PasswordCommand)
+	set("vhost", p.Vhost)
+	set("queue", p.Queue)
+	set("declare", p.Declare)
+	set("queue-type", p.QueueType)
+	if len(p.QueueArgs) > 0 {
+		set("queue-arg", joinKeyValues(p.QueueArgs))
+	}
+	set("management-url", p.ManagementURL)
+	set("connection-name", p.Connection.Name)
+	set("heartbeat", p.Connection.Heartbeat)
+	if p.Connection.ChannelMax != 0 {
+		set("channel-max", strconv.Itoa(p.Connection.ChannelMax))
+	}
+	if p.Connection.FrameSize != 0 {
+		set("frame-size", strconv.Itoa(p.Connection.FrameSize))
+	}
+	set("locale", p.Connection.Locale)
+	set("kafka-acks", p.Kafka.Acks)
+	if p.Kafka.Idempotent != nil {
+		set("kafka-idempotent", strconv.FormatBool(*p.Kafka.Idempotent))
+	}
+	set("kafka-key", p.Kafka.Key)
+	if p.Redis.MaxLen != 0 {
+		set("redis-maxlen", strconv.FormatInt(p.Redis.MaxLen, 10))
+	}
+	if p.MQTT.QoS != nil {
+		set("mqtt-qos", strconv.Itoa(*p.MQTT.QoS))
+	}
+	if p.MQTT.Retain {
+		set("mqtt-retain", "true")
+	}
+	if len(p.HTTP.Headers) > 0 {
+		set("http-header", joinKeyValues(p.HTTP.Headers))
+	}
+	set("http-header-prefix", p.HTTP.HeaderPrefix)
+	if p.HTTP.Retries != nil {
+		set("http-retries", strconv.Itoa(*p.HTTP.Retries))
+	}
+	set("sink-format", p.Sink.Format)
+	if p.Delay != nil {
+		set("delay", strconv.Itoa(*p.Delay))
+	}
+	if p.TLS.Insecure {
+		set("insecure", "true")
+	}
+	set("tls-ca", p.TLS.CA)
+	set("tls-cert", p.TLS.Cert)
+	set("tls-key", p.TLS.Key)
+	set("tls-server-name", p.TLS.ServerName)
+	set("tls-min-version", p.TLS.MinVersion)
+
+	return values
+}
+
+// joinKeyValues formats a map as the key=value flags such as --queue-arg take it
+func joinKeyValues(args map[string]string) string {
+	pairs := make([]string, 0, len(args))
+	for k, v := range args {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
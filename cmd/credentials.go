@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/marianozunino/go-publish/internal/redact"
+	"github.com/marianozunino/go-publish/internal/sink"
+)
+
+var (
+	userName        string
+	passwordFile    string
+	passwordCommand string
+)
+
+// resolveCredentials puts the configured user and password into every URI.
+// The password comes from whichever source has the highest precedence:
+// --password-file, --password-command or GO_PUBLISH_PASSWORD, each layered
+// as flag > env var > profile. A password in the URI keeps precedence over
+// one from a lower source, e.g. --uri over a profile's password_file.
+// Without any, the URIs are left untouched.
+func resolveCredentials(uris []string) ([]string, error) {
+	password, source, ok, err := resolvePassword()
+	if err != nil {
+		return nil, err
+	}
+	if !ok && userName == "" {
+		return uris, nil
+	}
+
+	resolved := make([]string, len(uris))
+	for i, uri := range uris {
+		usePassword := ok && (source >= settingSources["uri"] || !hasPassword(uri))
+		if resolved[i], err = withCredentials(uri, password, usePassword); err != nil {
+			return nil, err
+		}
+	}
+	return resolved, nil
+}
+
+// withCredentials sets the user and, when hasPassword, the password of uri
+func withCredentials(uri, password string, hasPassword bool) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		// The parse error quotes the URI, password and all
+		return "", fmt.Errorf("invalid URI %q", redact.URI(uri))
+	}
+	// Files and stdout have no one to log in as
+	if uri == sink.Stdout || u.Scheme == "file" {
+		return uri, nil
+	}
+
+	user := userName
+	if user == "" && u.User != nil {
+		user = u.User.Username()
+	}
+	if !hasPassword && u.User != nil {
+		password, hasPassword = u.User.Password()
+	}
+
+	if hasPassword {
+		u.User = url.UserPassword(user, password)
+	} else {
+		u.User = url.User(user)
+	}
+	return u.String(), nil
+}
+
+// hasPassword reports whether uri carries a password
+func hasPassword(uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil || u.User == nil {
+		return false
+	}
+	_, ok := u.User.Password()
+	return ok
+}
+
+// resolvePassword returns the password from the highest precedence source,
+// and that source
+func resolvePassword() (string, settingSource, bool, error) {
+	type candidate struct {
+		source settingSource
+		read   func() (string, error)
+	}
+
+	var candidates []candidate
+	if passwordFile != "" {
+		candidates = append(candidates, candidate{settingSources["password-file"], readPasswordFile})
+	}
+	if passwordCommand != "" {
+		candidates = append(candidates, candidate{settingSources["password-command"], runPasswordCommand})
+	}
+	if password, ok := os.LookupEnv("GO_PUBLISH_PASSWORD"); ok {
+		candidates = append(candidates, candidate{sourceEnv, func() (string, error) { return password, nil }})
+	}
+	if len(candidates) == 0 {
+		return "", sourceDefault, false, nil
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].source > candidates[j].source
+	})
+	password, err := candidates[0].read()
+	if err != nil {
+		return "", sourceDefault, false, err
+	}
+	return password, candidates[0].source, true, nil
+}
+
+// readPasswordFile reads the password from --password-file
+func readPasswordFile() (string, error) {
+	data, err := os.ReadFile(passwordFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read password file: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// runPasswordCommand runs --password-command through the shell and uses
+// the first line it prints as the password, like pass(1) does
+func runPasswordCommand() (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("sh", "-c", passwordCommand)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("password command failed: %w: %s", err, msg)
+		}
+		return "", fmt.Errorf("password command failed: %w", err)
+	}
+
+	password, _, _ := strings.Cut(string(out), "\n")
+	password = strings.TrimRight(password, "\r")
+	if password == "" {
+		return "", fmt.Errorf("password command printed no password")
+	}
+	return password, nil
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// credentialSources sets where the password options came from, undoing it
+// when the test ends. The file holds "from-file" and the command prints
+// "from-command".
+type credentialSources struct {
+	file, command, uri settingSource // sourceDefault leaves the option unset
+	env                bool          // set GO_PUBLISH_PASSWORD to "from-env"
+	user               string
+}
+
+func (c credentialSources) apply(t *testing.T) {
+	t.Helper()
+	saved := struct {
+		file, command, user string
+		sources             map[string]settingSource
+	}{passwordFile, passwordCommand, userName, settingSources}
+	t.Cleanup(func() {
+		passwordFile, passwordCommand, userName = saved.file, saved.command, saved.user
+		settingSources = saved.sources
+	})
+
+	passwordFile, passwordCommand, userName = "", "", c.user
+	settingSources = map[string]settingSource{}
+	if c.file != sourceDefault {
+		path := filepath.Join(t.TempDir(), "password")
+		if err := os.WriteFile(path, []byte("from-file\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		passwordFile = path
+		settingSources["password-file"] = c.file
+	}
+	if c.command != sourceDefault {
+		passwordCommand = "echo from-command"
+		settingSources["password-command"] = c.command
+	}
+	if c.uri != sourceDefault {
+		settingSources["uri"] = c.uri
+	}
+	if c.env {
+		t.Setenv("GO_PUBLISH_PASSWORD", "from-env")
+	} else {
+		// Unset for the test, restored afterwards
+		t.Setenv("GO_PUBLISH_PASSWORD", "")
+		os.Unsetenv("GO_PUBLISH_PASSWORD")
+	}
+}
+
+func TestResolvePassword(t *testing.T) {
+	tests := []struct {
+		name    string
+		sources credentialSources
+		want    string // empty when no password is configured
+	}{
+		{"none", credentialSources{}, ""},
+		{"env", credentialSources{env: true}, "from-env"},
+		{"profile file", credentialSources{file: sourceProfile}, "from-file"},
+		{"profile command", credentialSources{command: sourceProfile}, "from-command"},
+		{"env beats profile file", credentialSources{file: sourceProfile, env: true}, "from-env"},
+		{"env beats profile command", credentialSources{command: sourceProfile, env: true}, "from-env"},
+		{"env file beats env password", credentialSources{file: sourceEnv, env: true}, "from-file"},
+		{"flag file beats env", credentialSources{file: sourceFlag, env: true}, "from-file"},
+		{"flag command beats env", credentialSources{command: sourceFlag, env: true}, "from-command"},
+		{"flag command beats profile file", credentialSources{file: sourceProfile, command: sourceFlag}, "from-command"},
+		{"env command beats profile file", credentialSources{file: sourceProfile, command: sourceEnv}, "from-command"},
+		{"file beats command from the same source", credentialSources{file: sourceFlag, command: sourceFlag}, "from-file"},
+		{"profile file beats profile command", credentialSources{file: sourceProfile, command: sourceProfile}, "from-file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.sources.apply(t)
+			password, _, ok, err := resolvePassword()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != (tt.want != "") || password != tt.want {
+				t.Errorf("resolvePassword() = %q, %v, want %q", password, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveCredentials(t *testing.T) {
+	tests := []struct {
+		name    string
+		uri     string
+		sources credentialSources
+		want    string
+	}{
+		{"untouched", "amqp://guest:guest@h/", credentialSources{}, "amqp://guest:guest@h/"},
+		{"fills in a URI without password", "amqp://guest@h/",
+			credentialSources{file: sourceProfile}, "amqp://guest:from-file@h/"},
+		{"flag URI password beats profile file", "amqp://guest:from-uri@h/",
+			credentialSources{uri: sourceFlag, file: sourceProfile}, "amqp://guest:from-uri@h/"},
+		{"flag URI password beats profile command", "amqp://guest:from-uri@h/",
+			credentialSources{uri: sourceFlag, command: sourceProfile}, "amqp://guest:from-uri@h/"},
+		{"flag URI password beats env", "amqp://guest:from-uri@h/",
+			credentialSources{uri: sourceFlag, env: true}, "amqp://guest:from-uri@h/"},
+		{"flag file beats flag URI password", "amqp://guest:from-uri@h/",
+			credentialSources{uri: sourceFlag, file: sourceFlag}, "amqp://guest:from-file@h/"},
+		{"env beats profile URI password", "amqp://guest:from-uri@h/",
+			credentialSources{uri: sourceProfile, env: true}, "amqp://guest:from-env@h/"},
+		{"default URI password is replaced", "amqp://guest:guest@h/",
+			credentialSources{file: sourceProfile}, "amqp://guest:from-file@h/"},
+		{"user replaced, password kept", "amqp://guest:guest@h/",
+			credentialSources{user: "alice"}, "amqp://alice:guest@h/"},
+		{"user and password", "amqp://h/",
+			credentialSources{user: "alice", env: true}, "amqp://alice:from-env@h/"},
+		{"stdout untouched", "-", credentialSources{env: true}, "-"},
+		{"file untouched", "file://out.ndjson", credentialSources{env: true}, "file://out.ndjson"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.sources.apply(t)
+			got, err := resolveCredentials([]string{tt.uri})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got[0] != tt.want {
+				t.Errorf("resolveCredentials(%q) = %q, want %q", tt.uri, got[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveCredentialsKeepsPasswordOutOfErrors(t *testing.T) {
+	credentialSources{env: true}.apply(t)
+	_, err := resolveCredentials([]string{"amqp://guest:s3cret-pw@h:bad port/"})
+	if err == nil {
+		t.Fatal("invalid URI accepted")
+	}
+	if got := err.Error(); got != `invalid URI "amqp://guest:xxxxx@h:bad port/"` {
+		t.Errorf("error = %q", got)
+	}
+}
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/marianozunino/go-publish/internal/generator"
+	"github.com/marianozunino/go-publish/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+func NewGenerateCmd() *cobra.Command {
+	var (
+		templateFile string
+		templateBody string
+		count        int
+		contentType  string
+		deliveryMode int
+		priority     int
+		routingKey   string
+	)
+
+	generateCmd := &cobra.Command{
+		Use:   "generate",
+		Short: "Publish synthetic messages rendered from a template",
+		Long: `Render a Go text/template body for every message and publish the result,
+using the same interactive controls as a replay. Useful for load testing.
+
+Template data:
+  .Index  - zero-based message index
+  .Seq    - one-based sequence number
+  .Now    - time the message was rendered
+
+Template helpers:
+  uuid                  - random UUID (v4)
+  randInt MIN MAX       - random integer in [MIN, MAX]
+  randFloat MIN MAX     - random float in [MIN, MAX)
+  randString N          - random alphanumeric string of length N
+  pick A B ...          - one of the arguments at random (or pick (list A B ...))
+  now                   - current time
+  timestamp [LAYOUT]    - current UTC time, RFC3339 unless LAYOUT is given
+  unix / unixMilli      - current Unix time in seconds / milliseconds`,
+		Example: `go-publish generate -t order.tmpl -n 10000 -q orders
+go-publish generate -b '{"id":"{{uuid}}","seq":{{.Seq}}}' -m 0`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := applySettings(cmd); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			opts := generator.Options{
+				RoutingKey:   routingKey,
+				ContentType:  contentType,
+				DeliveryMode: deliveryMode,
+				Priority:     priority,
+			}
+
+			var gen *generator.Generator
+			var err error
+			switch {
+			case templateFile != "" && templateBody != "":
+				err = fmt.Errorf("--template and --body are mutually exclusive")
+			case templateFile != "":
+				gen, err = generator.NewFromFile(templateFile, opts)
+			case templateBody != "":
+				gen, err = generator.New(templateBody, opts)
+			default:
+				err = fmt.Errorf("one of --template or --body is required")
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			msgRate, err := parseRate(targetRate)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			orderKey, err := parseOrderKey(orderBy)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			// Render the first message up front so template errors surface before connecting
+			sample, err := gen.Render(0)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			if dryRun {
+				amount := "an unbounded number of"
+				if count > 0 {
+					amount = fmt.Sprintf("%d", count)
+				}
+				fmt.Printf("Dry run mode. %s messages would have been sent to queue: %s\n", amount, queueName)
+				fmt.Printf("Sample message:\n%s\n", sample.Payload)
+				return
+			}
+
+			// With messages going to stdout, the UI goes to stderr
+			status := os.Stdout
+			if writesToStdout(amqpURIs) {
+				status = os.Stderr
+			}
+
+			if err := guardProtected(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			connector, conn, err := connect(amqpURIs, queueName)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			preflight, err := runPreflight(conn, count)
+			if err != nil {
+				conn.Close()
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			if err := ui.StartTUI(ui.Options{
+				Generator:   gen,
+				Count:       count,
+				Connector:   connector,
+				Conn:        conn,
+				OrderKey:    orderKey,
+				QueueName:   queueName,
+				DelayMs:     initialDelay,
+				InsecureTLS: skipTLSVerify,
+				Rate:        msgRate,
+				Burst:       burst,
+				Preflight:   preflight,
+				Protected:   protectedTarget && !iAmSure,
+				Output:      status,
+			}); err != nil {
+				fmt.Fprintf(os.Stderr, "UI Error: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	generateCmd.Flags().StringVarP(&templateFile, "template", "t", "",
+		"File containing the message body template")
+	generateCmd.Flags().StringVarP(&templateBody, "body", "b", "",
+		"Inline message body template")
+	generateCmd.Flags().IntVarP(&count, "count", "n", 0,
+		"Number of messages to generate (0 = until quit)")
+	generateCmd.Flags().StringVar(&contentType, "content-type", "application/json",
+		"Content type of generated messages")
+	generateCmd.Flags().IntVar(&deliveryMode, "delivery-mode", 2,
+		"Delivery mode of generated messages (1 = transient, 2 = persistent)")
+	generateCmd.Flags().IntVar(&priority, "priority", 0,
+		"Priority of generated messages")
+	generateCmd.Flags().StringVar(&routingKey, "routing-key", "",
+		"Routing key of generated messages, the subject or topic on NATS and MQTT without --queue")
+
+	return generateCmd
+}
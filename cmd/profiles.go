@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/marianozunino/go-publish/internal/config"
+	"github.com/marianozunino/go-publish/internal/redact"
+	"github.com/spf13/cobra"
+)
+
+func NewProfilesCmd() *cobra.Command {
+	profilesCmd := &cobra.Command{
+		Use:   "profiles",
+		Short: "Inspect the connection profiles in the config file",
+	}
+
+	profilesCmd.AddCommand(&cobra.Command{
+		Use:     "list",
+		Short:   "List the available profiles",
+		Example: `go-publish profiles list`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg, err := loadConfig()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			if len(cfg.Profiles) == 0 {
+				fmt.Println("No profiles configured.")
+				return
+			}
+			for _, name := range cfg.ProfileNames() {
+				marker := " "
+				if name == cfg.DefaultProfile {
+					marker = "*"
+				}
+				fmt.Printf("%s %-20s %s\n", marker, name, profileURIs(cfg.Profiles[name]))
+			}
+		},
+	})
+
+	profilesCmd.AddCommand(&cobra.Command{
+		Use:     "show NAME",
+		Short:   "Show the settings of a profile",
+		Example: `go-publish profiles show staging`,
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg, err := loadConfig()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			profile, _, err := cfg.Profile(args[0])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			fmt.Printf("Profile: %s\n", args[0])
+			printSetting("URI", profileURIs(profile))
+			printSetting("Node order", profile.NodeOrder)
+			printSetting("User", profile.User)
+			printSetting("Password file", profile.PasswordFile)
+			printSetting("Password command", profile.PasswordCommand)
+			printSetting("Vhost", profile.Vhost)
+			printSetting("Queue", profile.Queue)
+			printSetting("Declare", profile.Declare)
+			printSetting("Queue type", profile.QueueType)
+			if len(profile.QueueArgs) > 0 {
+				printSetting("Queue args", joinKeyValues(profile.QueueArgs))
+			}
+			if profile.ManagementURL != "" {
+				printSetting("Management URL", redact.URI(profile.ManagementURL))
+			}
+			if profile.Delay != nil {
+				printSetting("Delay", fmt.Sprintf("%dms", *profile.Delay))
+			}
+			printSetting("Connection name", profile.Connection.Name)
+			printSetting("Heartbeat", profile.Connection.Heartbeat)
+			if profile.Connection.ChannelMax != 0 {
+				printSetting("Channel max", strconv.Itoa(profile.Connection.ChannelMax))
+			}
+			if profile.Connection.FrameSize != 0 {
+				printSetting("Frame size", strconv.Itoa(profile.Connection.FrameSize))
+			}
+			printSetting("Locale", profile.Connection.Locale)
+			printSetting("Kafka acks", profile.Kafka.Acks)
+			if profile.Kafka.Idempotent != nil {
+				printSetting("Kafka idempotent", strconv.FormatBool(*profile.Kafka.Idempotent))
+			}
+			printSetting("Kafka key", profile.Kafka.Key)
+			if profile.Redis.MaxLen != 0 {
+				printSetting("Redis max length", strconv.FormatInt(profile.Redis.MaxLen, 10))
+			}
+			if profile.MQTT.QoS != nil {
+				printSetting("MQTT QoS", strconv.Itoa(*profile.MQTT.QoS))
+			}
+			if profile.MQTT.Retain {
+				printSetting("MQTT retain", "true")
+			}
+			if len(profile.HTTP.Headers) > 0 {
+				printSetting("HTTP headers", joinKeyValues(profile.HTTP.Headers))
+			}
+			printSetting("HTTP header prefix", profile.HTTP.HeaderPrefix)
+			if profile.HTTP.Retries != nil {
+				printSetting("HTTP retries", strconv.Itoa(*profile.HTTP.Retries))
+			}
+			printSetting("Sink format", profile.Sink.Format)
+			printSetting("TLS CA", profile.TLS.CA)
+			printSetting("TLS cert", profile.TLS.Cert)
+			printSetting("TLS key", profile.TLS.Key)
+			printSetting("TLS server name", profile.TLS.ServerName)
+			printSetting("TLS min version", profile.TLS.MinVersion)
+			if profile.TLS.Insecure {
+				printSetting("TLS verification", "disabled")
+			}
+		},
+	})
+
+	return profilesCmd
+}
+
+// profileURIs returns the masked URIs of a profile's nodes, comma-separated
+func profileURIs(p config.Profile) string {
+	uris := p.URIs
+	if len(uris) == 0 && p.URI != "" {
+		uris = []string{p.URI}
+	}
+	masked := make([]string, len(uris))
+	for i, uri := range uris {
+		masked[i] = redact.URI(uri)
+	}
+	return strings.Join(masked, ",")
+}
+
+// printSetting prints a labelled value, skipping empty ones
+func printSetting(label, value string) {
+	if value != "" {
+		fmt.Printf("  %-18s %s\n", label+":", value)
+	}
+}
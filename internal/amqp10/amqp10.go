@@ -0,0 +1,325 @@
+// Package amqp10 is the AMQP 1.0 publishing backend, for brokers such as
+// ActiveMQ Artemis and Azure Service Bus
+package amqp10
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/marianozunino/go-publish/internal/models"
+	"github.com/marianozunino/go-publish/internal/publisher"
+	"github.com/marianozunino/go-publish/internal/redact"
+	"github.com/marianozunino/go-publish/internal/tlsconfig"
+)
+
+// connectTimeout bounds opening the connection, session and links
+const connectTimeout = 10 * time.Second
+
+// Options describes the brokers to connect to and where messages go
+type Options struct {
+	URIs    []string // brokers tried one after another: amqp10://[user:pass@]host:port or amqp10s:// for TLS
+	TLS     tlsconfig.Options
+	Name    string // connection_name connection property
+	Version string // version connection property
+	Address string
+	Confirm bool // send unsettled and wait for the broker's disposition
+	Workers int  // links to open, one per publishing lane
+}
+
+// Dialer connects to the brokers in turn, moving on to the next one each
+// time it is asked to connect again
+type Dialer struct {
+	opts     Options
+	rotation publisher.Rotation
+}
+
+// NewDialer validates the options and returns a Dialer for them
+func NewDialer(opts Options) (*Dialer, error) {
+	if len(opts.URIs) == 0 {
+		return nil, fmt.Errorf("no AMQP 1.0 URI given")
+	}
+	if opts.Address == "" {
+		return nil, fmt.Errorf("no AMQP 1.0 address given")
+	}
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+
+	for _, uri := range opts.URIs {
+		u, err := url.Parse(uri)
+		if err != nil || (u.Scheme != "amqp10" && u.Scheme != "amqp10s") || u.Hostname() == "" {
+			return nil, fmt.Errorf("invalid AMQP 1.0 URI %q (expected amqp10://host:port or amqp10s://host:port)", redact.URI(uri))
+		}
+		if u.Scheme == "amqp10" && opts.TLS.IsSet() {
+			return nil, fmt.Errorf("TLS options require an amqp10s:// URI")
+		}
+	}
+
+	return &Dialer{opts: opts}, nil
+}
+
+// Connect tries every broker once, starting after the one connected to
+// last, and returns a sender on the first one that accepts it
+func (d *Dialer) Connect() (publisher.Publisher, error) {
+	return d.rotation.Connect(d.opts.URIs, "brokers", func(_ int, uri string) (publisher.Publisher, error) {
+		return d.connectBroker(uri)
+	})
+}
+
+// connectBroker opens a connection and session on one broker, with a link
+// to the address per worker
+func (d *Dialer) connectBroker(uri string) (*Sender, error) {
+	u, _ := url.Parse(uri)
+	cfg := &amqp.ConnOptions{
+		// Credentials in the URI switch to SASL PLAIN
+		SASLType: amqp.SASLTypeAnonymous(),
+		Properties: map[string]any{
+			"product": "go-publish",
+		},
+	}
+	if d.opts.Version != "" {
+		cfg.Properties["version"] = d.opts.Version
+	}
+	if d.opts.Name != "" {
+		cfg.Properties["connection_name"] = d.opts.Name
+	}
+
+	// go-amqp knows the standard schemes only
+	if u.Scheme == "amqp10s" {
+		u.Scheme = "amqps"
+		tlsCfg, err := d.opts.TLS.Build()
+		if err != nil {
+			return nil, err
+		}
+		cfg.TLSConfig = tlsCfg
+	} else {
+		u.Scheme = "amqp"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+
+	conn, err := amqp.Dial(ctx, u.String(), cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to AMQP 1.0 broker: %w", err)
+	}
+	s := &Sender{dialer: d, conn: conn, uri: uri, host: u.Host}
+
+	session, err := conn.NewSession(ctx, nil)
+	if err != nil {
+		s.Close()
+		return nil, fmt.Errorf("failed to open a session: %w", err)
+	}
+
+	mode := amqp.SenderSettleModeSettled
+	if d.opts.Confirm {
+		mode = amqp.SenderSettleModeUnsettled
+	}
+	for i := 0; i < d.opts.Workers; i++ {
+		link, err := session.NewSender(ctx, d.opts.Address, &amqp.SenderOptions{
+			SettlementMode: &mode,
+			// Ask brokers that tell queues from topics, like Artemis, for
+			// anycast delivery
+			TargetCapabilities: []string{"queue"},
+		})
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("failed to attach to address %q: %w", d.opts.Address, err)
+		}
+		s.links = append(s.links, link)
+	}
+
+	return s, nil
+}
+
+// Sender publishes messages to an address, one link per lane
+type Sender struct {
+	dialer *Dialer
+	conn   *amqp.Conn
+	links  []*amqp.Sender
+	uri    string
+	host   string
+
+	mu      sync.Mutex
+	closing bool
+}
+
+// Lanes returns the number of links
+func (s *Sender) Lanes() int {
+	return len(s.links)
+}
+
+// Publish transfers msg on the lane's link. With confirmation the transfer
+// is unsettled and only an accepted disposition counts as published;
+// otherwise it is sent settled and counts once written.
+func (s *Sender) Publish(lane int, msg models.RawMessage) error {
+	ctx := context.Background()
+	link := s.links[lane]
+
+	var err error
+	if s.dialer.opts.Confirm {
+		var receipt amqp.SendReceipt
+		if receipt, err = link.SendWithReceipt(ctx, message(msg), nil); err == nil {
+			err = disposition(receipt.Wait(ctx))
+		}
+	} else {
+		err = link.Send(ctx, message(msg), nil)
+	}
+	if err == nil {
+		return nil
+	}
+
+	if isConnectionError(err) {
+		return &publisher.ConnectionError{Err: redact.Error(err, redact.Password(s.uri))}
+	}
+	return redact.Error(err, redact.Password(s.uri))
+}
+
+// Confirm returns nil: Publish already waited for the disposition
+func (s *Sender) Confirm(lane int) error {
+	return nil
+}
+
+// Health returns a channel receiving an error once the connection is lost
+func (s *Sender) Health() <-chan error {
+	lost := make(chan error, 1)
+	go func() {
+		<-s.conn.Done()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.closing {
+			return
+		}
+		err := s.conn.Err()
+		if err == nil {
+			err = errors.New("connection closed")
+		}
+		lost <- redact.Error(err, redact.Password(s.uri))
+	}()
+	return lost
+}
+
+// Target describes the broker
+func (s *Sender) Target() publisher.Target {
+	return publisher.Target{
+		URI:         redact.URI(s.uri),
+		Host:        s.host,
+		Connections: 1,
+	}
+}
+
+// Close closes the connection, and with it the session and links
+func (s *Sender) Close() error {
+	s.mu.Lock()
+	s.closing = true
+	s.mu.Unlock()
+
+	if err := s.conn.Close(); err != nil {
+		var connErr *amqp.ConnError
+		if errors.As(err, &connErr) {
+			// Closing an already failed connection reports why it failed
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// message maps a message to an AMQP 1.0 message: the payload is the data
+// section, the AMQP 0-9-1 properties become the header and properties
+// sections and the headers become application properties
+func message(msg models.RawMessage) *amqp.Message {
+	props := msg.Properties
+	m := amqp.NewMessage([]byte(msg.Payload))
+
+	m.Header = &amqp.MessageHeader{
+		Durable:  props.DeliveryMode == 2,
+		Priority: 4, // the AMQP 1.0 default
+	}
+	if props.Priority != 0 {
+		m.Header.Priority = uint8(props.Priority)
+	}
+
+	m.Properties = &amqp.MessageProperties{}
+	if props.MessageID != "" {
+		m.Properties.MessageID = props.MessageID
+	}
+	if props.ContentType != "" {
+		m.Properties.ContentType = &props.ContentType
+	}
+	if msg.RoutingKey != "" {
+		m.Properties.Subject = &msg.RoutingKey
+	}
+	if props.Timestamp != 0 {
+		created := time.Unix(props.Timestamp, 0)
+		m.Properties.CreationTime = &created
+	}
+
+	if len(props.Headers) > 0 {
+		m.ApplicationProperties = make(map[string]any, len(props.Headers))
+		for name, value := range props.Headers {
+			m.ApplicationProperties[name] = applicationProperty(value)
+		}
+	}
+	return m
+}
+
+// applicationProperty converts a decoded JSON header value into one of the
+// simple types application properties are restricted to, JSON-encoding
+// objects and arrays
+func applicationProperty(v interface{}) any {
+	switch val := v.(type) {
+	case nil, string, bool:
+		return val
+	case float64:
+		// JSON has no integer type; keep whole numbers as longs
+		if val == float64(int64(val)) {
+			return int64(val)
+		}
+		return val
+	default:
+		b, _ := json.Marshal(val)
+		return string(b)
+	}
+}
+
+// disposition turns the outcome of an unsettled transfer into an error
+// unless the broker accepted the message
+func disposition(state amqp.DeliveryState, err error) error {
+	if err != nil {
+		return err
+	}
+	switch state := state.(type) {
+	case *amqp.StateAccepted:
+		return nil
+	case *amqp.StateRejected:
+		if state.Error != nil {
+			return fmt.Errorf("message rejected: %w", state.Error)
+		}
+		return errors.New("message rejected")
+	case *amqp.StateReleased:
+		return errors.New("message released by the broker without being processed")
+	case *amqp.StateModified:
+		return errors.New("message not delivered (modified disposition)")
+	default:
+		return fmt.Errorf("unexpected disposition %T", state)
+	}
+}
+
+// isConnectionError reports whether err means the connection, session or
+// link is gone, as opposed to the broker refusing a particular message
+func isConnectionError(err error) bool {
+	var connErr *amqp.ConnError
+	var sessionErr *amqp.SessionError
+	var linkErr *amqp.LinkError
+	var netErr net.Error
+	return errors.As(err, &connErr) || errors.As(err, &sessionErr) ||
+		(errors.As(err, &linkErr) && linkErr.RemoteErr == nil) || errors.As(err, &netErr)
+}
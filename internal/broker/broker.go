@@ -0,0 +1,283 @@
+// Package broker is the RabbitMQ (AMQP 0-9-1) publishing backend
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/marianozunino/go-publish/internal/publisher"
+	"github.com/marianozunino/go-publish/internal/tlsconfig"
+	"github.com/streadway/amqp"
+)
+
+// Options describes the broker nodes to connect to and how
+type Options struct {
+	URIs       []string // nodes of the cluster, tried one after another
+	Random     bool     // try the nodes in random rather than the given order
+	Vhost      string   // overrides the vhost of the URIs when set
+	TLS        tlsconfig.Options
+	Connection ConnectionOptions
+	QueueName  string
+	Declare    DeclareMode
+	QueueType  string     // x-queue-type when declaring: classic, quorum or stream
+	QueueArgs  amqp.Table // extra x- arguments when declaring
+	// ManagementURL is the base URL of the management API, e.g.
+	// http://host:15672, to read the queue's arguments and policy from
+	ManagementURL string
+	Confirm       bool // wait for publisher confirms
+	Workers       int  // channels to open, one per publishing lane
+	Connections   int  // connections the channels are spread across
+}
+
+// ConnectionOptions are the AMQP connection parameters
+type ConnectionOptions struct {
+	Name       string        // connection_name client property shown in the management UI
+	Version    string        // version client property
+	Heartbeat  time.Duration // less than 1s uses the server's interval
+	ChannelMax int           // 0 uses the server's limit
+	FrameSize  int           // 0 uses the server's limit
+	Locale     string
+}
+
+// DeclareMode is how the target queue is set up on connect
+type DeclareMode string
+
+const (
+	DeclareQueue   DeclareMode = "declare" // create the queue unless it already exists
+	DeclarePassive DeclareMode = "passive" // only check that the queue exists
+	DeclareSkip    DeclareMode = "skip"    // leave the queue alone
+)
+
+// Dialer connects to the nodes of a cluster, failing over to the next node
+// each time it is asked to connect again
+type Dialer struct {
+	opts     Options
+	nodes    []string // the URIs in the order they are tried
+	rotation publisher.Rotation
+}
+
+// NewDialer returns a Dialer for the given options
+func NewDialer(opts Options) (*Dialer, error) {
+	if len(opts.URIs) == 0 {
+		return nil, fmt.Errorf("no broker URI given")
+	}
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+	if opts.Connections < 1 {
+		opts.Connections = 1
+	}
+	if opts.Connections > opts.Workers {
+		opts.Connections = opts.Workers
+	}
+	if opts.Connection.Locale == "" {
+		opts.Connection.Locale = "en_US"
+	}
+
+	// Random order is drawn once, so that failing over still visits every
+	// node before coming back to one
+	nodes := append([]string(nil), opts.URIs...)
+	if opts.Random {
+		rand.Shuffle(len(nodes), func(i, j int) { nodes[i], nodes[j] = nodes[j], nodes[i] })
+	}
+
+	return &Dialer{opts: opts, nodes: nodes}, nil
+}
+
+// Nodes returns the number of nodes the dialer knows about
+func (d *Dialer) Nodes() int {
+	return len(d.opts.URIs)
+}
+
+// Connect tries every node once, starting after the one connected to last,
+// and returns a session on the first node that accepts it
+func (d *Dialer) Connect() (publisher.Publisher, error) {
+	return d.rotation.Connect(d.nodes, "nodes", func(_ int, uri string) (publisher.Publisher, error) {
+		return d.connectNode(uri)
+	})
+}
+
+// connectNode opens the connections and channels of a session on one node
+// and makes sure the target queue exists
+func (d *Dialer) connectNode(uri string) (*Session, error) {
+	session := &Session{Node: uri, Vhost: d.opts.Vhost, opts: d.opts}
+	if session.Vhost == "" {
+		if parsed, err := amqp.ParseURI(uri); err == nil {
+			session.Vhost = parsed.Vhost
+		}
+	}
+	fail := func(err error) (*Session, error) {
+		session.Close()
+		return nil, err
+	}
+
+	for i := 0; i < d.opts.Connections; i++ {
+		conn, err := d.dial(uri, d.connectionName(i))
+		if err != nil {
+			return fail(fmt.Errorf("failed to connect to RabbitMQ: %w", err))
+		}
+		session.Connections = append(session.Connections, conn)
+	}
+
+	for i := 0; i < d.opts.Workers; i++ {
+		ch, err := session.Connections[i%len(session.Connections)].Channel()
+		if err != nil {
+			return fail(fmt.Errorf("failed to open a channel: %w", err))
+		}
+		session.Channels = append(session.Channels, ch)
+
+		if d.opts.Confirm {
+			if err := ch.Confirm(false); err != nil {
+				return fail(fmt.Errorf("failed to enable publisher confirms: %w", err))
+			}
+			session.confirms = append(session.confirms, ch.NotifyPublish(make(chan amqp.Confirmation, 1)))
+		}
+	}
+
+	if err := d.declareQueue(session.Channels[0]); err != nil {
+		return fail(err)
+	}
+
+	return session, nil
+}
+
+// declareQueue makes sure the target queue exists, as the declare mode asks
+func (d *Dialer) declareQueue(ch *amqp.Channel) error {
+	switch d.opts.Declare {
+	case DeclareSkip:
+		return nil
+	case DeclarePassive:
+		if _, err := ch.QueueDeclarePassive(d.opts.QueueName, true, false, false, false, nil); err != nil {
+			var amqpErr *amqp.Error
+			if errors.As(err, &amqpErr) && amqpErr.Code == amqp.NotFound {
+				return fmt.Errorf("queue %q not found: %w", d.opts.QueueName, err)
+			}
+			return fmt.Errorf("failed to check queue %q: %w", d.opts.QueueName, err)
+		}
+		return nil
+	}
+
+	args := amqp.Table{}
+	for k, v := range d.opts.QueueArgs {
+		args[k] = v
+	}
+	if d.opts.QueueType != "" {
+		args["x-queue-type"] = d.opts.QueueType
+	}
+	if len(args) == 0 {
+		args = nil
+	}
+
+	_, err := ch.QueueDeclare(
+		d.opts.QueueName, // name
+		true,             // durable
+		false,            // delete when unused
+		false,            // exclusive
+		false,            // no-wait
+		args,             // arguments
+	)
+	if err != nil {
+		var amqpErr *amqp.Error
+		if errors.As(err, &amqpErr) && amqpErr.Code == amqp.PreconditionFailed {
+			return fmt.Errorf("failed to declare queue: %w (the queue exists with other settings; "+
+				"match them with --queue-type/--queue-arg or use --declare=passive)", err)
+		}
+		return fmt.Errorf("failed to declare queue: %w", err)
+	}
+	return nil
+}
+
+// connectionName returns the name of the i-th connection, numbering them
+// when a session has more than one
+func (d *Dialer) connectionName(i int) string {
+	name := d.opts.Connection.Name
+	if name == "" || d.opts.Connections == 1 {
+		return name
+	}
+	return fmt.Sprintf("%s (%d/%d)", name, i+1, d.opts.Connections)
+}
+
+// dial opens a single connection
+func (d *Dialer) dial(uri, name string) (*amqp.Connection, error) {
+	conn := d.opts.Connection
+	// An empty vhost means the one from the URI
+	cfg := amqp.Config{
+		Heartbeat:  conn.Heartbeat,
+		ChannelMax: conn.ChannelMax,
+		FrameSize:  conn.FrameSize,
+		Locale:     conn.Locale,
+		Vhost:      d.opts.Vhost,
+		// A fresh table per connection, as the client adds its capabilities to it
+		Properties: amqp.Table{"product": "go-publish"},
+	}
+	if conn.Version != "" {
+		cfg.Properties["version"] = conn.Version
+	}
+	if name != "" {
+		cfg.Properties["connection_name"] = name
+	}
+
+	if !strings.HasPrefix(uri, "amqps://") {
+		if d.opts.TLS.IsSet() {
+			return nil, fmt.Errorf("TLS options require an amqps:// URI")
+		}
+		// Use standard connection
+		return amqp.DialConfig(uri, cfg)
+	}
+
+	tlsCfg, err := d.opts.TLS.Build()
+	if err != nil {
+		return nil, err
+	}
+	cfg.TLSClientConfig = tlsCfg
+
+	// With a client certificate, prefer SASL EXTERNAL (the broker derives the
+	// user from the certificate) and fall back to the URI credentials
+	if d.opts.TLS.HasClientCert() {
+		parsed, err := amqp.ParseURI(uri)
+		if err != nil {
+			return nil, err
+		}
+		cfg.SASL = []amqp.Authentication{
+			externalAuth{},
+			&amqp.PlainAuth{Username: parsed.Username, Password: parsed.Password},
+		}
+	}
+
+	return amqp.DialConfig(uri, cfg)
+}
+
+// externalAuth implements the SASL EXTERNAL mechanism, which authenticates
+// with the identity of the TLS client certificate
+type externalAuth struct{}
+
+// Mechanism returns "EXTERNAL"
+func (externalAuth) Mechanism() string {
+	return "EXTERNAL"
+}
+
+// Response is empty: the identity comes from the certificate
+func (externalAuth) Response() string {
+	return ""
+}
+
+// isConnectionError reports whether err means the connection or channel is
+// gone, as opposed to the broker refusing a particular message
+func isConnectionError(err error) bool {
+	if err == amqp.ErrClosed {
+		return true
+	}
+
+	var amqpErr *amqp.Error
+	if errors.As(err, &amqpErr) {
+		return amqpErr.Code == amqp.ConnectionForced || amqpErr.Code == amqp.FrameError
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF)
+}
@@ -0,0 +1,134 @@
+package broker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/marianozunino/go-publish/internal/redact"
+	"github.com/streadway/amqp"
+)
+
+// managementTimeout bounds the request for the details of the target queue
+const managementTimeout = 5 * time.Second
+
+// queueDetails is what the management API reports about a queue
+type queueDetails struct {
+	Arguments amqp.Table `json:"arguments"`
+	Policy    string     `json:"policy"`
+	// effective_policy_definition is an empty list rather than an object
+	// on older brokers when no policy applies
+	EffectivePolicy json.RawMessage `json:"effective_policy_definition"`
+}
+
+// policyDefinition returns the settings of the policy in effect, if any
+func (q *queueDetails) policyDefinition() (amqp.Table, error) {
+	definition := amqp.Table{}
+	raw := strings.TrimSpace(string(q.EffectivePolicy))
+	if raw == "" || raw == "null" || raw == "[]" {
+		return definition, nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&definition); err != nil {
+		return nil, fmt.Errorf("invalid policy definition: %w", err)
+	}
+	return definition, nil
+}
+
+// inspectManaged reads the arguments and effective policy of the target
+// queue from the management API. The credentials of the management URL are
+// used when it has any, otherwise those of the node.
+func (s *Session) inspectManaged() (*queueDetails, error) {
+	base, err := url.Parse(s.opts.ManagementURL)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("invalid management URL %q", redact.URI(s.opts.ManagementURL))
+	}
+
+	user := base.User
+	base.User = nil
+	if user == nil {
+		if node, err := amqp.ParseURI(s.Node); err == nil {
+			user = url.UserPassword(node.Username, node.Password)
+		}
+	}
+
+	endpoint := strings.TrimRight(base.String(), "/") + "/api/queues/" +
+		url.PathEscape(s.Vhost) + "/" + url.PathEscape(s.opts.QueueName)
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if user != nil {
+		password, _ := user.Password()
+		req.SetBasicAuth(user.Username(), password)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if base.Scheme == "https" {
+		tlsCfg, err := s.opts.TLS.Build()
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsCfg
+	}
+	client := &http.Client{Transport: transport, Timeout: managementTimeout}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return nil, fmt.Errorf("%s answered %s", redact.URI(s.opts.ManagementURL), resp.Status)
+	}
+
+	var details queueDetails
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&details); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	return &details, nil
+}
+
+// effectiveArgs merges a policy definition into the queue arguments the way
+// the broker applies them: a policy setting counts where the queue has no
+// argument for it, and of two length limits the lower one wins
+func effectiveArgs(args, policy amqp.Table) amqp.Table {
+	effective := amqp.Table{}
+	for k, v := range args {
+		effective[k] = v
+	}
+	for k, v := range policy {
+		key := "x-" + k
+		current, set := effective[key]
+		if !set {
+			effective[key] = v
+			continue
+		}
+		if key == "x-max-length" || key == "x-max-length-bytes" {
+			limit, ok := intArg(v)
+			if existing, ok2 := intArg(current); ok && ok2 && limit < existing {
+				effective[key] = v
+			}
+		}
+	}
+	return effective
+}
+
+// keyValues formats a table as key=value pairs sorted by key
+func keyValues(t amqp.Table) []string {
+	pairs := make([]string, 0, len(t))
+	for k, v := range t {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", k, v))
+	}
+	sort.Strings(pairs)
+	return pairs
+}
@@ -0,0 +1,63 @@
+package broker
+
+import (
+	"time"
+
+	"github.com/marianozunino/go-publish/internal/models"
+	"github.com/streadway/amqp"
+)
+
+// publishing converts a message into an AMQP publishing
+func publishing(msg models.RawMessage) amqp.Publishing {
+	return amqp.Publishing{
+		ContentType:  msg.Properties.ContentType,
+		DeliveryMode: uint8(msg.Properties.DeliveryMode),
+		Priority:     uint8(msg.Properties.Priority),
+		MessageId:    msg.Properties.MessageID,
+		Headers:      toTable(msg.Properties.Headers),
+		Timestamp:    timestamp(msg.Properties.Timestamp),
+		Body:         []byte(msg.Payload),
+	}
+}
+
+// toTable converts decoded JSON headers into an AMQP table
+func toTable(headers map[string]interface{}) amqp.Table {
+	if len(headers) == 0 {
+		return nil
+	}
+	table := make(amqp.Table, len(headers))
+	for k, v := range headers {
+		table[k] = toFieldValue(v)
+	}
+	return table
+}
+
+// toFieldValue converts nested JSON values into types the AMQP encoder accepts
+func toFieldValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		return toTable(val)
+	case []interface{}:
+		arr := make([]interface{}, len(val))
+		for i, item := range val {
+			arr[i] = toFieldValue(item)
+		}
+		return arr
+	case float64:
+		// JSON has no integer type; keep whole numbers as AMQP longs
+		if val == float64(int64(val)) {
+			return int64(val)
+		}
+		return val
+	default:
+		return val
+	}
+}
+
+// timestamp converts a Unix timestamp property, leaving it unset when zero
+func timestamp(unix int64) time.Time {
+	if unix == 0 {
+		return time.Time{}
+	}
+	return time.Unix(unix, 0)
+}
@@ -0,0 +1,178 @@
+package broker
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/marianozunino/go-publish/internal/publisher"
+	"github.com/marianozunino/go-publish/internal/redact"
+	"github.com/streadway/amqp"
+)
+
+// probeTimeout is how long to wait for the broker to confirm the permission probe
+const probeTimeout = 5 * time.Second
+
+// Preflight checks that the session may publish and inspects the target queue,
+// warning about anything likely to make count messages go astray. Each check
+// runs on a channel of its own, as a failed check closes its channel.
+func (s *Session) Preflight(count int) (*publisher.Preflight, error) {
+	p := &publisher.Preflight{}
+	conn := s.Connections[0]
+	queueName := s.opts.QueueName
+
+	if err := probePublish(conn); err != nil {
+		return nil, redact.Error(err, redact.Password(s.Node))
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open a channel: %w", err)
+	}
+	defer ch.Close()
+
+	q, err := ch.QueueInspect(queueName)
+	var amqpErr *amqp.Error
+	switch {
+	case errors.As(err, &amqpErr) && amqpErr.Code == amqp.NotFound:
+		p.Warnings = append(p.Warnings, fmt.Sprintf(
+			"queue %q does not exist: the default exchange will drop every message", queueName))
+		return p, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to inspect queue: %w", err)
+	}
+
+	p.Exists = true
+	p.Messages = q.Messages
+	p.Consumers = q.Consumers
+	if q.Consumers == 0 {
+		p.Warnings = append(p.Warnings, "no consumers: messages will pile up in the queue")
+	}
+
+	// Arguments and policies cannot be read over AMQP; the management API
+	// knows both. Without it, the arguments are only known when we declared
+	// the queue with them and the broker accepted the declaration.
+	switch {
+	case s.opts.ManagementURL != "":
+		details, err := s.inspectManaged()
+		var policy amqp.Table
+		if err == nil {
+			policy, err = details.policyDefinition()
+		}
+		if err != nil {
+			p.Unknown = "management API: " + redact.Error(err, redact.Password(s.opts.ManagementURL), redact.Password(s.Node)).Error()
+			break
+		}
+		p.Arguments = keyValues(details.Arguments)
+		p.Policy = details.Policy
+		p.PolicyDefinition = keyValues(policy)
+		p.Warnings = append(p.Warnings, capacityWarnings(effectiveArgs(details.Arguments, policy), p.Messages, count)...)
+	case s.opts.Declare == DeclareQueue:
+		args := amqp.Table{}
+		for k, v := range s.opts.QueueArgs {
+			args[k] = v
+		}
+		if s.opts.QueueType != "" {
+			args["x-queue-type"] = s.opts.QueueType
+		}
+		p.Arguments = keyValues(args)
+		p.Unknown = "no --management-url to read it from"
+		p.Warnings = append(p.Warnings, capacityWarnings(args, p.Messages, count)...)
+	default:
+		p.Unknown = "queue not declared and no --management-url to read it from"
+	}
+
+	return p, nil
+}
+
+// probePublish publishes an empty message to a routing key no queue is bound
+// to and waits for the broker to confirm it, which fails when the user lacks
+// write permission on the default exchange
+func probePublish(conn *amqp.Connection) error {
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open a channel: %w", err)
+	}
+	defer ch.Close()
+
+	if err := ch.Confirm(false); err != nil {
+		return fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+	confirms := ch.NotifyPublish(make(chan amqp.Confirmation, 1))
+	closed := ch.NotifyClose(make(chan *amqp.Error, 1))
+
+	if err := ch.Publish("", probeKey(), false, false, amqp.Publishing{}); err != nil {
+		return fmt.Errorf("cannot publish: %w", err)
+	}
+
+	select {
+	case c, ok := <-confirms:
+		if ok && c.Ack {
+			return nil
+		}
+		if !ok {
+			// The channel closed first; its error says why
+			if err := <-closed; err != nil {
+				return fmt.Errorf("cannot publish: %w", err)
+			}
+		}
+		return fmt.Errorf("cannot publish: the broker rejected a test message")
+	case err := <-closed:
+		return fmt.Errorf("cannot publish: %w", err)
+	case <-time.After(probeTimeout):
+		return fmt.Errorf("cannot publish: no confirmation from the broker within %s", probeTimeout)
+	}
+}
+
+// probeKey returns a routing key unlikely to match any queue
+func probeKey() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "go-publish.preflight." + hex.EncodeToString(b)
+}
+
+// capacityWarnings flags declared length limits the queue is at or will reach
+func capacityWarnings(args amqp.Table, depth, count int) []string {
+	var warnings []string
+
+	overflow, _ := args["x-overflow"].(string)
+	maxLength, limited := intArg(args["x-max-length"])
+
+	switch {
+	case !limited:
+	case depth >= maxLength:
+		warnings = append(warnings, fmt.Sprintf("queue is at its max-length of %d", maxLength))
+	case count > 0 && depth+count > maxLength:
+		warnings = append(warnings, fmt.Sprintf("only %d of %d messages fit under the max-length of %d",
+			maxLength-depth, count, maxLength))
+	}
+
+	if overflow == "reject-publish" || overflow == "reject-publish-dlx" {
+		warnings = append(warnings, fmt.Sprintf(
+			"overflow is %s: messages beyond the length limit are refused", overflow))
+	} else if limited {
+		warnings = append(warnings, "overflow drops the oldest messages beyond the length limit")
+	}
+
+	return warnings
+}
+
+// intArg returns a queue argument as an int, if it is an integer
+func intArg(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int32:
+		return int(n), true
+	case int64:
+		return int(n), true
+	case json.Number:
+		i, err := n.Int64()
+		return int(i), err == nil
+	default:
+		return 0, false
+	}
+}
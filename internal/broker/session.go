@@ -0,0 +1,118 @@
+package broker
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/marianozunino/go-publish/internal/models"
+	"github.com/marianozunino/go-publish/internal/publisher"
+	"github.com/marianozunino/go-publish/internal/redact"
+	"github.com/streadway/amqp"
+)
+
+// Session is a set of connections and channels to a single node. It publishes
+// to the target queue through the default exchange, one lane per channel.
+type Session struct {
+	Node        string // URI of the node the session is connected to
+	Vhost       string // virtual host the session is connected to
+	Connections []*amqp.Connection
+	Channels    []*amqp.Channel
+
+	opts     Options
+	confirms []chan amqp.Confirmation // one per channel with publisher confirms
+}
+
+// Lanes returns the number of channels
+func (s *Session) Lanes() int {
+	return len(s.Channels)
+}
+
+// Publish publishes msg to the target queue on the lane's channel
+func (s *Session) Publish(lane int, msg models.RawMessage) error {
+	err := s.Channels[lane].Publish(
+		"",               // exchange (empty for direct to queue)
+		s.opts.QueueName, // routing key (queue name)
+		false,            // mandatory
+		false,            // immediate
+		publishing(msg),
+	)
+	return s.wrap(err)
+}
+
+// Confirm waits for the broker to confirm the last message published on the
+// lane, when publisher confirms are enabled
+func (s *Session) Confirm(lane int) error {
+	if s.confirms == nil {
+		return nil
+	}
+	c, ok := <-s.confirms[lane]
+	if !ok {
+		return s.wrap(amqp.ErrClosed)
+	}
+	if !c.Ack {
+		return fmt.Errorf("message %d was rejected by the broker", c.DeliveryTag)
+	}
+	return nil
+}
+
+// Health returns a channel receiving the first error closing any of the
+// session's connections. A graceful Close delivers nothing.
+func (s *Session) Health() <-chan error {
+	lost := make(chan error, 1)
+	for _, conn := range s.Connections {
+		closed := conn.NotifyClose(make(chan *amqp.Error, 1))
+		go func() {
+			if err, ok := <-closed; ok && err != nil {
+				select {
+				case lost <- s.wrap(err):
+				default:
+				}
+			}
+		}()
+	}
+	return lost
+}
+
+// Target describes the node and vhost of the session
+func (s *Session) Target() publisher.Target {
+	return publisher.Target{
+		URI:         redact.URI(s.Node),
+		Host:        s.host(),
+		Vhost:       s.Vhost,
+		Connections: len(s.Connections),
+	}
+}
+
+// Close closes channels before the connections they belong to
+func (s *Session) Close() error {
+	for _, ch := range s.Channels {
+		ch.Close()
+	}
+	for _, conn := range s.Connections {
+		conn.Close()
+	}
+	return nil
+}
+
+// host returns the host and port of the node the session is connected to
+func (s *Session) host() string {
+	uri, err := amqp.ParseURI(s.Node)
+	if err != nil {
+		return ""
+	}
+	return net.JoinHostPort(uri.Host, strconv.Itoa(uri.Port))
+}
+
+// wrap redacts err and marks it as a connection error when the connection
+// or channel is gone
+func (s *Session) wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+	redacted := redact.Error(err, redact.Password(s.Node))
+	if isConnectionError(err) {
+		return &publisher.ConnectionError{Err: redacted}
+	}
+	return redacted
+}
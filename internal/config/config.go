@@ -0,0 +1,173 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the contents of the go-publish configuration file
+type Config struct {
+	DefaultProfile string             `yaml:"default_profile"`
+	Profiles       map[string]Profile `yaml:"profiles"`
+	ProtectedURIs  []string           `yaml:"protected_uris"` // patterns of production targets
+}
+
+// Profile holds the connection settings for one broker
+type Profile struct {
+	URI             string            `yaml:"uri"`
+	URIs            []string          `yaml:"uris"`       // cluster nodes, instead of uri
+	NodeOrder       string            `yaml:"node_order"` // ordered or random
+	User            string            `yaml:"user"`
+	PasswordFile    string            `yaml:"password_file"`
+	PasswordCommand string            `yaml:"password_command"` // e.g. "pass show rabbit/prod"
+	Vhost           string            `yaml:"vhost"`
+	Queue           string            `yaml:"queue"`
+	Declare         string            `yaml:"declare"`        // declare, passive or skip
+	QueueType       string            `yaml:"queue_type"`     // classic, quorum or stream
+	QueueArgs       map[string]string `yaml:"queue_args"`     // e.g. x-message-ttl: 60000
+	ManagementURL   string            `yaml:"management_url"` // e.g. http://host:15672
+	Delay           *int              `yaml:"delay"`          // milliseconds; a pointer so 0 can be told apart from unset
+	Connection      Connection        `yaml:"connection"`
+	TLS             TLS               `yaml:"tls"`
+	Kafka           Kafka             `yaml:"kafka"`
+	Redis           Redis             `yaml:"redis"`
+	MQTT            MQTT              `yaml:"mqtt"`
+	HTTP            HTTP              `yaml:"http"`
+	Sink            Sink              `yaml:"sink"`
+	Protected       bool              `yaml:"protected"` // ask for confirmation before publishing
+}
+
+// Connection holds the AMQP connection parameters of a profile
+type Connection struct {
+	Name       string `yaml:"name"`
+	Heartbeat  string `yaml:"heartbeat"` // e.g. 30s
+	ChannelMax int    `yaml:"channel_max"`
+	FrameSize  int    `yaml:"frame_size"`
+	Locale     string `yaml:"locale"`
+}
+
+// Kafka holds the Kafka producer settings of a profile
+type Kafka struct {
+	Acks       string `yaml:"acks"`
+	Idempotent *bool  `yaml:"idempotent"` // a pointer so false can be told apart from unset
+	Key        string `yaml:"key"`
+}
+
+// Redis holds the Redis Streams settings of a profile
+type Redis struct {
+	MaxLen int64 `yaml:"maxlen"` // approximate stream length to trim to
+}
+
+// MQTT holds the MQTT publish settings of a profile
+type MQTT struct {
+	QoS    *int `yaml:"qos"` // a pointer so 0 can be told apart from unset
+	Retain bool `yaml:"retain"`
+}
+
+// HTTP holds the webhook settings of a profile
+type HTTP struct {
+	Headers      map[string]string `yaml:"headers"` // message header name to HTTP header name
+	HeaderPrefix string            `yaml:"header_prefix"`
+	Retries      *int              `yaml:"retries"` // a pointer so 0 can be told apart from unset
+}
+
+// Sink holds the file and stdout output settings of a profile
+type Sink struct {
+	Format string `yaml:"format"` // ndjson or body
+}
+
+// TLS holds the TLS settings of a profile
+type TLS struct {
+	CA         string `yaml:"ca"`
+	Cert       string `yaml:"cert"`
+	Key        string `yaml:"key"`
+	ServerName string `yaml:"server_name"`
+	MinVersion string `yaml:"min_version"`
+	Insecure   bool   `yaml:"insecure"`
+}
+
+// DefaultPath returns the location of the configuration file,
+// e.g. ~/.config/go-publish/config.yaml on Linux
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate config directory: %w", err)
+	}
+	return filepath.Join(dir, "go-publish", "config.yaml"), nil
+}
+
+// Load reads the configuration file. A missing file yields an empty
+// configuration, so go-publish works without one.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// Profile returns the named profile, or the default profile when name is
+// empty. ok is false when no profile is selected at all.
+func (c *Config) Profile(name string) (profile Profile, ok bool, err error) {
+	if name == "" {
+		name = c.DefaultProfile
+	}
+	if name == "" {
+		return Profile{}, false, nil
+	}
+
+	profile, found := c.Profiles[name]
+	if !found {
+		return Profile{}, false, fmt.Errorf("profile %q not found in config file", name)
+	}
+	return profile, true, nil
+}
+
+// IsProtected returns true if uri matches one of the protected URI patterns.
+// A pattern is matched against the host name and against the URI without its
+// credentials, and * matches any run of characters, e.g. *.prod.example.com
+// or amqps://rabbit-prod-*.
+func (c *Config) IsProtected(uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return false
+	}
+	u.User = nil
+
+	for _, pattern := range c.ProtectedURIs {
+		re, err := regexp.Compile("^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$")
+		if err != nil {
+			continue
+		}
+		if re.MatchString(u.Hostname()) || re.MatchString(u.String()) {
+			return true
+		}
+	}
+	return false
+}
+
+// ProfileNames returns the profile names in alphabetical order
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
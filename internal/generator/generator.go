@@ -0,0 +1,169 @@
+package generator
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	mrand "math/rand"
+	"os"
+	"text/template"
+	"time"
+
+	"github.com/marianozunino/go-publish/internal/models"
+)
+
+const randomAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// Options holds the message properties applied to every generated message
+type Options struct {
+	RoutingKey   string
+	ContentType  string
+	DeliveryMode int
+	Priority     int
+}
+
+// Generator renders synthetic messages from a text/template body
+type Generator struct {
+	tmpl    *template.Template
+	options Options
+}
+
+// TemplateData is the data passed to the body template on each render
+type TemplateData struct {
+	Index int       // zero-based position of the message in the run
+	Seq   int       // one-based sequence number
+	Now   time.Time // time the message was rendered
+}
+
+// New parses the template body and returns a Generator
+func New(body string, opts Options) (*Generator, error) {
+	tmpl, err := template.New("body").Funcs(funcMap()).Option("missingkey=error").Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	return &Generator{tmpl: tmpl, options: opts}, nil
+}
+
+// NewFromFile reads the template body from a file and returns a Generator
+func NewFromFile(filePath string, opts Options) (*Generator, error) {
+	body, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template file: %w", err)
+	}
+
+	return New(string(body), opts)
+}
+
+// Render produces the message at the given zero-based index
+func (g *Generator) Render(index int) (models.RawMessage, error) {
+	var buf bytes.Buffer
+	data := TemplateData{
+		Index: index,
+		Seq:   index + 1,
+		Now:   time.Now(),
+	}
+	if err := g.tmpl.Execute(&buf, data); err != nil {
+		return models.RawMessage{}, fmt.Errorf("failed to render message %d: %w", index, err)
+	}
+
+	return models.RawMessage{
+		PayloadBytes: buf.Len(),
+		RoutingKey:   g.options.RoutingKey,
+		Properties: models.MessageProperties{
+			Priority:     g.options.Priority,
+			DeliveryMode: g.options.DeliveryMode,
+			ContentType:  g.options.ContentType,
+		},
+		Payload:         buf.String(),
+		PayloadEncoding: "string",
+	}, nil
+}
+
+// funcMap returns the helpers available inside templates
+func funcMap() template.FuncMap {
+	return template.FuncMap{
+		"uuid":       newUUID,
+		"randInt":    randInt,
+		"randFloat":  randFloat,
+		"randString": randString,
+		"pick":       pick,
+		"list":       list,
+		"now":        time.Now,
+		"timestamp":  timestamp,
+		"unix":       func() int64 { return time.Now().Unix() },
+		"unixMilli":  func() int64 { return time.Now().UnixMilli() },
+	}
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// randInt returns a random integer in [min, max]
+func randInt(min, max int) (int, error) {
+	if max < min {
+		return 0, fmt.Errorf("randInt: max (%d) is lower than min (%d)", max, min)
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max-min)+1))
+	if err != nil {
+		return 0, err
+	}
+	return min + int(n.Int64()), nil
+}
+
+// randFloat returns a random float in [min, max)
+func randFloat(min, max float64) float64 {
+	return min + mrand.Float64()*(max-min)
+}
+
+// randString returns a random alphanumeric string of length n
+func randString(n int) (string, error) {
+	if n < 0 {
+		return "", fmt.Errorf("randString: negative length %d", n)
+	}
+	b := make([]byte, n)
+	for i := range b {
+		idx, err := rand.Int(rand.Reader, big.NewInt(int64(len(randomAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = randomAlphabet[idx.Int64()]
+	}
+	return string(b), nil
+}
+
+// list builds a slice from its arguments, for use with pick
+func list(items ...interface{}) []interface{} {
+	return items
+}
+
+// pick returns one of its arguments at random. A single slice argument
+// (as built by list) is picked from instead.
+func pick(items ...interface{}) (interface{}, error) {
+	if len(items) == 1 {
+		if l, ok := items[0].([]interface{}); ok {
+			items = l
+		}
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("pick: no items given")
+	}
+	return items[mrand.Intn(len(items))], nil
+}
+
+// timestamp returns the current time formatted with the given layout, or RFC3339 when omitted
+func timestamp(layout ...string) string {
+	if len(layout) == 0 {
+		return time.Now().UTC().Format(time.RFC3339)
+	}
+	return time.Now().UTC().Format(layout[0])
+}
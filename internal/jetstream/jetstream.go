@@ -0,0 +1,236 @@
+// Package jetstream is the NATS JetStream publishing backend
+package jetstream
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marianozunino/go-publish/internal/models"
+	"github.com/marianozunino/go-publish/internal/publisher"
+	"github.com/marianozunino/go-publish/internal/redact"
+	"github.com/marianozunino/go-publish/internal/tlsconfig"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	// connectTimeout bounds connecting to each server
+	connectTimeout = 10 * time.Second
+	// ackTimeout bounds how long a publish waits for the stream to acknowledge it
+	ackTimeout = 10 * time.Second
+)
+
+// Options describes the servers to connect to and where messages go
+type Options struct {
+	URIs    []string // servers of the cluster: nats://[user:pass@]host:port
+	TLS     tlsconfig.Options
+	Name    string // connection name shown in the server's monitoring
+	Subject string // subject of every message; the routing key of each when empty
+	Workers int    // messages published concurrently
+}
+
+// Dialer connects to a NATS cluster. The client moves between the servers of
+// the cluster by itself, so every URI is handed to it on each connect.
+type Dialer struct {
+	opts  Options
+	hosts []string
+}
+
+// NewDialer validates the options and returns a Dialer for them
+func NewDialer(opts Options) (*Dialer, error) {
+	if len(opts.URIs) == 0 {
+		return nil, fmt.Errorf("no NATS URI given")
+	}
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+
+	hosts := make([]string, len(opts.URIs))
+	for i, uri := range opts.URIs {
+		u, err := url.Parse(uri)
+		if err != nil || u.Scheme != "nats" || u.Host == "" {
+			return nil, fmt.Errorf("invalid NATS URI %q (expected nats://host:port)", redact.URI(uri))
+		}
+		hosts[i] = u.Host
+	}
+
+	return &Dialer{opts: opts, hosts: hosts}, nil
+}
+
+// Connect connects to the first server that answers and checks that
+// JetStream is available on it
+func (d *Dialer) Connect() (publisher.Publisher, error) {
+	p := &Producer{dialer: d, lost: make(chan error, 1)}
+
+	nopts := []nats.Option{
+		nats.Timeout(connectTimeout),
+		nats.ClosedHandler(p.closed),
+	}
+	if d.opts.Name != "" {
+		nopts = append(nopts, nats.Name(d.opts.Name))
+	}
+	if d.opts.TLS.IsSet() {
+		tlsCfg, err := d.opts.TLS.Build()
+		if err != nil {
+			return nil, err
+		}
+		nopts = append(nopts, nats.Secure(tlsCfg))
+	}
+
+	conn, err := nats.Connect(strings.Join(d.opts.URIs, ","), nopts...)
+	if err != nil {
+		return nil, publisher.RedactURIs(fmt.Errorf("failed to connect to NATS: %w", err), d.opts.URIs...)
+	}
+	p.conn = conn
+
+	js, err := conn.JetStream()
+	if err == nil {
+		_, err = js.AccountInfo()
+	}
+	if err != nil {
+		p.Close()
+		return nil, publisher.RedactURIs(fmt.Errorf("JetStream is not available: %w", err), d.opts.URIs...)
+	}
+	p.js = js
+
+	return p, nil
+}
+
+// Producer publishes messages to JetStream subjects
+type Producer struct {
+	dialer *Dialer
+	conn   *nats.Conn
+	js     nats.JetStreamContext
+
+	mu      sync.Mutex
+	closing bool
+	lost    chan error
+}
+
+// Lanes returns the number of workers; the connection is safe for concurrent use
+func (p *Producer) Lanes() int {
+	return p.dialer.opts.Workers
+}
+
+// Publish sends msg and waits for the stream to acknowledge it, so that only
+// messages a stream has stored count as published
+func (p *Producer) Publish(lane int, msg models.RawMessage) error {
+	m, err := p.message(msg)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.js.PublishMsg(m, nats.AckWait(ackTimeout))
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, nats.ErrNoStreamResponse) {
+		err = fmt.Errorf("%w (is a stream bound to subject %q?)", err, m.Subject)
+	}
+	if p.isConnectionError(err) {
+		return &publisher.ConnectionError{Err: publisher.RedactURIs(err, p.dialer.opts.URIs...)}
+	}
+	return publisher.RedactURIs(err, p.dialer.opts.URIs...)
+}
+
+// Confirm returns nil: Publish already waited for the acknowledgement
+func (p *Producer) Confirm(lane int) error {
+	return nil
+}
+
+// Health returns a channel receiving an error once the client gives up
+// reconnecting; it rides out shorter outages by itself
+func (p *Producer) Health() <-chan error {
+	return p.lost
+}
+
+// Target describes the servers
+func (p *Producer) Target() publisher.Target {
+	host := strings.Join(p.dialer.hosts, ",")
+	if p.conn != nil {
+		if addr := p.conn.ConnectedAddr(); addr != "" {
+			host = addr
+		}
+	}
+	return publisher.Target{
+		URI:         redact.URI(p.dialer.opts.URIs[0]),
+		Host:        host,
+		Connections: 1,
+	}
+}
+
+// Close closes the connection
+func (p *Producer) Close() error {
+	p.mu.Lock()
+	p.closing = true
+	p.mu.Unlock()
+
+	if p.conn != nil {
+		p.conn.Close()
+	}
+	return nil
+}
+
+// closed is called by the client once the connection is closed for good
+func (p *Producer) closed(conn *nats.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closing {
+		return
+	}
+
+	err := conn.LastError()
+	if err == nil {
+		err = nats.ErrConnectionClosed
+	}
+	select {
+	case p.lost <- publisher.RedactURIs(err, p.dialer.opts.URIs...):
+	default:
+	}
+}
+
+// message maps a message to a NATS message: the payload becomes the data,
+// AMQP headers become NATS headers and the message ID becomes Nats-Msg-Id,
+// which JetStream uses to drop duplicates
+func (p *Producer) message(msg models.RawMessage) (*nats.Msg, error) {
+	subject := p.dialer.opts.Subject
+	if subject == "" {
+		subject = msg.RoutingKey
+	}
+	if subject == "" {
+		return nil, fmt.Errorf("message has no routing key to use as subject; set one with --queue")
+	}
+
+	m := nats.NewMsg(subject)
+	m.Data = []byte(msg.Payload)
+
+	for name, value := range msg.Properties.Headers {
+		m.Header.Set(name, publisher.HeaderString(value))
+	}
+	if msg.Properties.ContentType != "" {
+		m.Header.Set("Content-Type", msg.Properties.ContentType)
+	}
+	if msg.Properties.MessageID != "" {
+		m.Header.Set(nats.MsgIdHdr, msg.Properties.MessageID)
+	}
+	return m, nil
+}
+
+// isConnectionError reports whether err means the server could not be
+// reached, as opposed to a stream refusing the message
+func (p *Producer) isConnectionError(err error) bool {
+	switch {
+	case errors.Is(err, nats.ErrConnectionClosed),
+		errors.Is(err, nats.ErrConnectionDraining),
+		errors.Is(err, nats.ErrConnectionReconnecting),
+		errors.Is(err, nats.ErrDisconnected):
+		return true
+	case errors.Is(err, nats.ErrTimeout):
+		// A timeout while the client is reconnecting is the outage showing
+		return !p.conn.IsConnected()
+	}
+	return false
+}
@@ -0,0 +1,278 @@
+// Package kafka is the Apache Kafka publishing backend
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/marianozunino/go-publish/internal/models"
+	"github.com/marianozunino/go-publish/internal/ordering"
+	"github.com/marianozunino/go-publish/internal/publisher"
+	"github.com/marianozunino/go-publish/internal/redact"
+	"github.com/marianozunino/go-publish/internal/tlsconfig"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+)
+
+const (
+	// defaultPort is used for seed brokers given without a port
+	defaultPort = "9092"
+	// connectTimeout bounds the initial check that a seed broker answers
+	connectTimeout = 10 * time.Second
+	// deliveryTimeout bounds how long a record may be retried before it fails
+	deliveryTimeout = 30 * time.Second
+)
+
+// Options describes the cluster to produce to and how
+type Options struct {
+	URIs       []string // seed brokers: kafka://[user:pass@]host:port or kafkas:// for TLS
+	TLS        tlsconfig.Options
+	ClientID   string
+	Topic      string
+	Acks       string           // all, leader or none
+	Idempotent bool             // deduplicate retried produce requests; requires acks=all
+	Key        ordering.KeyFunc // record key of a message, the routing key when nil
+	Workers    int              // messages produced concurrently
+}
+
+// Dialer creates producers for a cluster. The client spreads records over
+// the whole cluster itself, so every seed broker is used on each connect.
+type Dialer struct {
+	opts  Options
+	seeds []string
+	kopts []kgo.Opt
+}
+
+// NewDialer validates the options and returns a Dialer for them
+func NewDialer(opts Options) (*Dialer, error) {
+	if len(opts.URIs) == 0 {
+		return nil, fmt.Errorf("no Kafka URI given")
+	}
+	if opts.Topic == "" {
+		return nil, fmt.Errorf("no Kafka topic given")
+	}
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+	if opts.Key == nil {
+		opts.Key = func(msg models.RawMessage) string { return msg.RoutingKey }
+	}
+
+	kopts := []kgo.Opt{
+		kgo.DefaultProduceTopic(opts.Topic),
+		kgo.RecordDeliveryTimeout(deliveryTimeout),
+		// Records are produced one at a time per lane; don't wait to batch them
+		kgo.ProducerLinger(0),
+	}
+	if opts.ClientID != "" {
+		kopts = append(kopts, kgo.ClientID(opts.ClientID))
+	}
+
+	switch opts.Acks {
+	case "", "all":
+		kopts = append(kopts, kgo.RequiredAcks(kgo.AllISRAcks()))
+	case "leader":
+		kopts = append(kopts, kgo.RequiredAcks(kgo.LeaderAck()))
+	case "none":
+		kopts = append(kopts, kgo.RequiredAcks(kgo.NoAck()))
+	default:
+		return nil, fmt.Errorf("invalid acks %q (expected all, leader or none)", opts.Acks)
+	}
+	if opts.Idempotent && opts.Acks != "" && opts.Acks != "all" {
+		return nil, fmt.Errorf("an idempotent producer requires acks=all")
+	}
+	if !opts.Idempotent {
+		kopts = append(kopts, kgo.DisableIdempotentWrite())
+	}
+
+	var seeds []string
+	var useTLS bool
+	var mechanism sasl.Mechanism
+	for _, uri := range opts.URIs {
+		seed, secure, m, err := parseURI(uri)
+		if err != nil {
+			return nil, err
+		}
+		seeds = append(seeds, seed)
+		useTLS = useTLS || secure
+		if mechanism == nil {
+			mechanism = m
+		}
+	}
+	kopts = append(kopts, kgo.SeedBrokers(seeds...))
+
+	if useTLS {
+		tlsCfg, err := opts.TLS.Build()
+		if err != nil {
+			return nil, err
+		}
+		kopts = append(kopts, kgo.DialTLSConfig(tlsCfg))
+	} else if opts.TLS.IsSet() {
+		return nil, fmt.Errorf("TLS options require a kafkas:// URI")
+	}
+	if mechanism != nil {
+		kopts = append(kopts, kgo.SASL(mechanism))
+	}
+
+	return &Dialer{opts: opts, seeds: seeds, kopts: kopts}, nil
+}
+
+// Connect creates a client and checks that the cluster answers
+func (d *Dialer) Connect() (publisher.Publisher, error) {
+	client, err := kgo.NewClient(d.kopts...)
+	if err != nil {
+		return nil, publisher.RedactURIs(fmt.Errorf("failed to create Kafka client: %w", err), d.opts.URIs...)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	if err := client.Ping(ctx); err != nil {
+		client.Close()
+		return nil, publisher.RedactURIs(fmt.Errorf("failed to connect to Kafka: %w", err), d.opts.URIs...)
+	}
+
+	return &Producer{client: client, dialer: d}, nil
+}
+
+// Producer publishes messages as records of the topic
+type Producer struct {
+	client *kgo.Client
+	dialer *Dialer
+}
+
+// Lanes returns the number of workers; the client is safe for concurrent use
+func (p *Producer) Lanes() int {
+	return p.dialer.opts.Workers
+}
+
+// Publish produces msg and waits for the acknowledgement the acks setting asks for
+func (p *Producer) Publish(lane int, msg models.RawMessage) error {
+	err := p.client.ProduceSync(context.Background(), p.record(msg)).FirstErr()
+	if err == nil {
+		return nil
+	}
+	if isConnectionError(err) {
+		return &publisher.ConnectionError{Err: publisher.RedactURIs(err, p.dialer.opts.URIs...)}
+	}
+	return publisher.RedactURIs(err, p.dialer.opts.URIs...)
+}
+
+// Confirm returns nil: Publish already waited for the acknowledgement
+func (p *Producer) Confirm(lane int) error {
+	return nil
+}
+
+// Health returns a channel that never fires, as the client reconnects to
+// brokers by itself; a lost cluster shows as failing publishes instead
+func (p *Producer) Health() <-chan error {
+	return make(chan error)
+}
+
+// Target describes the seed brokers
+func (p *Producer) Target() publisher.Target {
+	return publisher.Target{
+		URI:         redact.URI(p.dialer.opts.URIs[0]),
+		Host:        strings.Join(p.dialer.seeds, ","),
+		Connections: 1,
+	}
+}
+
+// Close flushes nothing, as every record was produced synchronously, and
+// closes the client
+func (p *Producer) Close() error {
+	p.client.Close()
+	return nil
+}
+
+// record maps a message to a Kafka record: the payload becomes the value,
+// the key comes from the key function and AMQP headers become record headers
+func (p *Producer) record(msg models.RawMessage) *kgo.Record {
+	rec := &kgo.Record{
+		Topic: p.dialer.opts.Topic,
+		Value: []byte(msg.Payload),
+	}
+	if key := p.dialer.opts.Key(msg); key != "" {
+		rec.Key = []byte(key)
+	}
+	if msg.Properties.Timestamp != 0 {
+		rec.Timestamp = time.Unix(msg.Properties.Timestamp, 0)
+	}
+
+	names := make([]string, 0, len(msg.Properties.Headers))
+	for name := range msg.Properties.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		header := kgo.RecordHeader{Key: name}
+		if value := msg.Properties.Headers[name]; value != nil {
+			header.Value = []byte(publisher.HeaderString(value))
+		}
+		rec.Headers = append(rec.Headers, header)
+	}
+	return rec
+}
+
+// parseURI returns the host:port of a seed broker, whether it uses TLS and
+// the SASL mechanism for its credentials, if any. The mechanism defaults to
+// PLAIN and can be chosen with ?sasl=scram-sha-256 or ?sasl=scram-sha-512.
+func parseURI(uri string) (string, bool, sasl.Mechanism, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", false, nil, fmt.Errorf("invalid Kafka URI %q", redact.URI(uri))
+	}
+
+	var secure bool
+	switch u.Scheme {
+	case "kafka":
+	case "kafkas":
+		secure = true
+	default:
+		return "", false, nil, fmt.Errorf("invalid Kafka URI %q: scheme must be kafka or kafkas", redact.URI(uri))
+	}
+	if u.Hostname() == "" {
+		return "", false, nil, fmt.Errorf("invalid Kafka URI %q: missing host", redact.URI(uri))
+	}
+	port := u.Port()
+	if port == "" {
+		port = defaultPort
+	}
+	seed := net.JoinHostPort(u.Hostname(), port)
+
+	if u.User == nil {
+		return seed, secure, nil, nil
+	}
+	user := u.User.Username()
+	pass, _ := u.User.Password()
+
+	switch mech := u.Query().Get("sasl"); mech {
+	case "", "plain":
+		return seed, secure, plain.Auth{User: user, Pass: pass}.AsMechanism(), nil
+	case "scram-sha-256":
+		return seed, secure, scram.Auth{User: user, Pass: pass}.AsSha256Mechanism(), nil
+	case "scram-sha-512":
+		return seed, secure, scram.Auth{User: user, Pass: pass}.AsSha512Mechanism(), nil
+	default:
+		return "", false, nil, fmt.Errorf("unsupported SASL mechanism %q (expected plain, scram-sha-256 or scram-sha-512)", mech)
+	}
+}
+
+// isConnectionError reports whether err means the cluster could not be
+// reached, as opposed to a broker refusing the record. A record timing out
+// is not one: the client already retried it for deliveryTimeout, and
+// publishing it again after failing over would time out the same way.
+func isConnectionError(err error) bool {
+	if errors.Is(err, kgo.ErrClientClosed) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -3,8 +3,12 @@ package models
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"strconv"
+	"time"
 )
 
 // RawMessage represents the input file message format
@@ -21,9 +25,12 @@ type RawMessage struct {
 
 // MessageProperties represents AMQP message properties
 type MessageProperties struct {
-	Priority     int    `json:"priority"`
-	DeliveryMode int    `json:"delivery_mode"`
-	ContentType  string `json:"content_type"`
+	Priority     int                    `json:"priority"`
+	DeliveryMode int                    `json:"delivery_mode"`
+	ContentType  string                 `json:"content_type"`
+	MessageID    string                 `json:"message_id,omitempty"`
+	Timestamp    int64                  `json:"timestamp,omitempty"`
+	Headers      map[string]interface{} `json:"headers,omitempty"`
 }
 
 // ParseMessageFile reads and parses messages from a file
@@ -65,3 +72,102 @@ func ParseMessageFile(filePath string) ([]RawMessage, error) {
 
 	return messages, nil
 }
+
+// EncodeMessage encodes a message as a line of a message file, without the
+// newline, filling in the payload size and encoding a management UI dump has
+func EncodeMessage(msg RawMessage) ([]byte, error) {
+	msg.PayloadBytes = len(msg.Payload)
+	if msg.PayloadEncoding == "" {
+		msg.PayloadEncoding = "string"
+	}
+	return json.Marshal(msg)
+}
+
+// WriteMessageFile writes messages to a file, one per line, the way
+// ParseMessageFile reads them. An existing file is left alone, as it may be
+// the very input the messages came from.
+func WriteMessageFile(filePath string, messages []RawMessage) error {
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("file exists: %s; choose another name", filePath)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+
+	out := bufio.NewWriter(file)
+	for _, msg := range messages {
+		line, err := EncodeMessage(msg)
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("failed to encode message: %w", err)
+		}
+		out.Write(line)
+		out.WriteByte('\n')
+	}
+	if err := out.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	return file.Close()
+}
+
+// Timestamp returns the time the message was originally published. When
+// header is empty the timestamp property is used, otherwise the named header,
+// which may hold Unix seconds, Unix milliseconds or an RFC3339 string.
+func (m RawMessage) Timestamp(header string) (time.Time, bool) {
+	if header == "" {
+		if m.Properties.Timestamp == 0 {
+			return time.Time{}, false
+		}
+		return time.Unix(m.Properties.Timestamp, 0), true
+	}
+
+	value, ok := m.Properties.Headers[header]
+	if !ok {
+		return time.Time{}, false
+	}
+
+	switch v := value.(type) {
+	case float64:
+		return unixTime(int64(v)), true
+	case string:
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return unixTime(n), true
+		}
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// unixTime interprets n as Unix milliseconds when it is too large to be seconds
+func unixTime(n int64) time.Time {
+	if n > 1e12 {
+		return time.UnixMilli(n)
+	}
+	return time.Unix(n, 0)
+}
+
+// TimingOffsets returns, for each message, how long after the first message
+// it was originally published. Every message must carry a timestamp.
+func TimingOffsets(messages []RawMessage, header string) ([]time.Duration, error) {
+	offsets := make([]time.Duration, len(messages))
+	var first time.Time
+	for i, msg := range messages {
+		ts, ok := msg.Timestamp(header)
+		if !ok {
+			source := "timestamp property"
+			if header != "" {
+				source = fmt.Sprintf("%q header", header)
+			}
+			return nil, fmt.Errorf("message %d has no usable %s", i+1, source)
+		}
+		if i == 0 {
+			first = ts
+		}
+		offsets[i] = ts.Sub(first)
+	}
+	return offsets, nil
+}
@@ -0,0 +1,313 @@
+// Package mqtt is the MQTT 5 publishing backend
+package mqtt
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eclipse/paho.golang/packets"
+	"github.com/eclipse/paho.golang/paho"
+	"github.com/marianozunino/go-publish/internal/models"
+	"github.com/marianozunino/go-publish/internal/publisher"
+	"github.com/marianozunino/go-publish/internal/redact"
+	"github.com/marianozunino/go-publish/internal/tlsconfig"
+)
+
+const (
+	// connectTimeout bounds dialing a server and waiting for its CONNACK
+	connectTimeout = 10 * time.Second
+	// keepAlive is the interval the client pings the server at
+	keepAlive = 30
+)
+
+// Options describes the servers to connect to and how messages are published
+type Options struct {
+	URIs     []string // servers tried one after another: mqtt://[user:pass@]host:port or mqtts:// for TLS
+	TLS      tlsconfig.Options
+	ClientID string // a random one when empty
+	Topic    string // topic of every message; the routing key of each when empty
+	QoS      byte   // 0, 1 or 2
+	Retain   bool
+	Workers  int // messages published concurrently
+}
+
+// Dialer connects to the servers in turn, moving on to the next one each
+// time it is asked to connect again
+type Dialer struct {
+	opts     Options
+	rotation publisher.Rotation
+}
+
+// NewDialer validates the options and returns a Dialer for them
+func NewDialer(opts Options) (*Dialer, error) {
+	if len(opts.URIs) == 0 {
+		return nil, fmt.Errorf("no MQTT URI given")
+	}
+	if opts.QoS > 2 {
+		return nil, fmt.Errorf("invalid QoS %d (expected 0, 1 or 2)", opts.QoS)
+	}
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+
+	for _, uri := range opts.URIs {
+		u, err := url.Parse(uri)
+		if err != nil || (u.Scheme != "mqtt" && u.Scheme != "mqtts") || u.Hostname() == "" {
+			return nil, fmt.Errorf("invalid MQTT URI %q (expected mqtt://host:port or mqtts://host:port)", redact.URI(uri))
+		}
+		if u.Scheme == "mqtt" && opts.TLS.IsSet() {
+			return nil, fmt.Errorf("TLS options require an mqtts:// URI")
+		}
+	}
+
+	if opts.ClientID == "" {
+		suffix := make([]byte, 4)
+		rand.Read(suffix)
+		opts.ClientID = "go-publish-" + hex.EncodeToString(suffix)
+	}
+
+	return &Dialer{opts: opts}, nil
+}
+
+// Connect tries every server once, starting after the one connected to
+// last, and returns a client on the first one that accepts it
+func (d *Dialer) Connect() (publisher.Publisher, error) {
+	return d.rotation.Connect(d.opts.URIs, "servers", func(_ int, uri string) (publisher.Publisher, error) {
+		return d.connectServer(uri)
+	})
+}
+
+// connectServer dials one server and opens an MQTT session on it
+func (d *Dialer) connectServer(uri string) (*Client, error) {
+	u, _ := url.Parse(uri)
+	host := u.Host
+	if u.Port() == "" {
+		port := "1883"
+		if u.Scheme == "mqtts" {
+			port = "8883"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	dialer := &net.Dialer{Timeout: connectTimeout}
+	var conn net.Conn
+	var err error
+	if u.Scheme == "mqtts" {
+		tlsCfg, tlsErr := d.opts.TLS.Build()
+		if tlsErr != nil {
+			return nil, tlsErr
+		}
+		if tlsCfg.ServerName == "" {
+			tlsCfg.ServerName = u.Hostname()
+		}
+		conn, err = tls.DialWithDialer(dialer, "tcp", host, tlsCfg)
+	} else {
+		conn, err = dialer.Dial("tcp", host)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MQTT server: %w", err)
+	}
+
+	c := &Client{dialer: d, uri: uri, host: host, lost: make(chan error, 1)}
+	c.client = paho.NewClient(paho.ClientConfig{
+		ClientID:      d.opts.ClientID,
+		Conn:          packets.NewThreadSafeConn(conn),
+		PacketTimeout: connectTimeout,
+		OnClientError: c.failed,
+		OnServerDisconnect: func(dc *paho.Disconnect) {
+			c.failed(fmt.Errorf("server disconnected: %s", reason((&packets.Disconnect{ReasonCode: dc.ReasonCode}).Reason())))
+		},
+	})
+
+	cp := &paho.Connect{
+		ClientID:   d.opts.ClientID,
+		KeepAlive:  keepAlive,
+		CleanStart: true,
+	}
+	if u.User != nil {
+		cp.Username = u.User.Username()
+		cp.UsernameFlag = true
+		if password, ok := u.User.Password(); ok {
+			cp.Password = []byte(password)
+			cp.PasswordFlag = true
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	ack, err := c.client.Connect(ctx, cp)
+	if err != nil {
+		if ack != nil && ack.ReasonCode >= 0x80 {
+			err = errors.New(reason((&packets.Connack{ReasonCode: ack.ReasonCode}).Reason()))
+		}
+		return nil, fmt.Errorf("failed to connect to MQTT server: %w", err)
+	}
+
+	if ack.Properties != nil {
+		if ack.Properties.MaximumQoS != nil && d.opts.QoS > *ack.Properties.MaximumQoS {
+			c.Close()
+			return nil, fmt.Errorf("the server only supports QoS up to %d", *ack.Properties.MaximumQoS)
+		}
+		if d.opts.Retain && !ack.Properties.RetainAvailable {
+			c.Close()
+			return nil, fmt.Errorf("the server does not support retained messages")
+		}
+	}
+
+	return c, nil
+}
+
+// Client publishes messages to MQTT topics
+type Client struct {
+	dialer *Dialer
+	client *paho.Client
+	uri    string
+	host   string
+
+	mu      sync.Mutex
+	closing bool
+	lost    chan error
+}
+
+// Lanes returns the number of workers; the client is safe for concurrent use
+func (c *Client) Lanes() int {
+	return c.dialer.opts.Workers
+}
+
+// Publish sends msg and, with QoS 1 or 2, waits for the server to
+// acknowledge it. QoS 0 messages count as published once written.
+func (c *Client) Publish(lane int, msg models.RawMessage) error {
+	p, err := c.publish(msg)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.Publish(context.Background(), p)
+	if err == nil && resp != nil && resp.ReasonCode >= 0x80 {
+		// A refused QoS 2 message ends with its PUBREC, which is not an error
+		err = fmt.Errorf("error publishing: %s", reason((&packets.Pubrec{ReasonCode: resp.ReasonCode}).Reason()))
+	}
+	if err == nil {
+		return nil
+	}
+
+	if c.isConnectionError(err) {
+		return &publisher.ConnectionError{Err: redact.Error(err, redact.Password(c.uri))}
+	}
+	return redact.Error(err, redact.Password(c.uri))
+}
+
+// Confirm returns nil: Publish already waited for the acknowledgement
+func (c *Client) Confirm(lane int) error {
+	return nil
+}
+
+// Health returns a channel receiving an error once the connection is lost
+func (c *Client) Health() <-chan error {
+	return c.lost
+}
+
+// Target describes the server
+func (c *Client) Target() publisher.Target {
+	return publisher.Target{
+		URI:         redact.URI(c.uri),
+		Host:        c.host,
+		Connections: 1,
+	}
+}
+
+// Close disconnects from the server
+func (c *Client) Close() error {
+	c.mu.Lock()
+	c.closing = true
+	c.mu.Unlock()
+
+	select {
+	case <-c.client.Done():
+		return nil
+	default:
+	}
+	return c.client.Disconnect(&paho.Disconnect{ReasonCode: 0})
+}
+
+// failed reports a lost connection, unless it is being closed on purpose
+func (c *Client) failed(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closing {
+		return
+	}
+	select {
+	case c.lost <- redact.Error(err, redact.Password(c.uri)):
+	default:
+	}
+}
+
+// publish maps a message to an MQTT publish: the payload is sent as is, the
+// AMQP headers become user properties and the content type is kept
+func (c *Client) publish(msg models.RawMessage) (*paho.Publish, error) {
+	opts := c.dialer.opts
+	topic := opts.Topic
+	if topic == "" {
+		topic = topicOf(msg.RoutingKey)
+	}
+	if topic == "" {
+		return nil, fmt.Errorf("message has no routing key to use as topic; set one with --queue")
+	}
+
+	props := &paho.PublishProperties{ContentType: msg.Properties.ContentType}
+	names := make([]string, 0, len(msg.Properties.Headers))
+	for name := range msg.Properties.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		props.User.Add(name, publisher.HeaderString(msg.Properties.Headers[name]))
+	}
+
+	return &paho.Publish{
+		QoS:        opts.QoS,
+		Retain:     opts.Retain,
+		Topic:      topic,
+		Properties: props,
+		Payload:    []byte(msg.Payload),
+	}, nil
+}
+
+// topicOf turns an AMQP routing key into an MQTT topic, e.g. sensors.eu.temp
+// into sensors/eu/temp
+func topicOf(routingKey string) string {
+	return strings.ReplaceAll(routingKey, ".", "/")
+}
+
+// reason shortens a reason code description to its name, e.g. "Not authorized"
+func reason(description string) string {
+	name, _, _ := strings.Cut(description, " - ")
+	return name
+}
+
+// isConnectionError reports whether err means the server could not be
+// reached, as opposed to the server refusing the message
+func (c *Client) isConnectionError(err error) bool {
+	select {
+	case <-c.client.Done():
+		return true
+	default:
+	}
+	if errors.Is(err, paho.ErrNetworkErrorAfterStored) || errors.Is(err, io.EOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
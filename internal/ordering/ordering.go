@@ -0,0 +1,100 @@
+package ordering
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"github.com/marianozunino/go-publish/internal/models"
+)
+
+// KeyFunc extracts the ordering key of a message. Messages sharing a key are
+// always published on the same lane, in their original order.
+type KeyFunc func(msg models.RawMessage) string
+
+// ParseKey builds a KeyFunc from a key spec:
+//
+//	routing_key    the message routing key
+//	message_id     the message_id property
+//	header:NAME    the value of header NAME
+//	json:PATH      the value at a dot-separated path in a JSON payload,
+//	               e.g. json:order.customer.id or json:$.items.0.sku
+func ParseKey(spec string) (KeyFunc, error) {
+	switch {
+	case spec == "routing_key":
+		return func(msg models.RawMessage) string {
+			return msg.RoutingKey
+		}, nil
+	case spec == "message_id":
+		return func(msg models.RawMessage) string {
+			return msg.Properties.MessageID
+		}, nil
+	case strings.HasPrefix(spec, "json:"):
+		path := strings.TrimPrefix(strings.TrimPrefix(spec, "json:"), "$.")
+		if path == "" {
+			return nil, fmt.Errorf("invalid ordering key %q: missing JSON path", spec)
+		}
+		segments := strings.Split(path, ".")
+		return func(msg models.RawMessage) string {
+			return jsonKey(msg.Payload, segments)
+		}, nil
+	case strings.HasPrefix(spec, "header:"):
+		name := strings.TrimPrefix(spec, "header:")
+		if name == "" {
+			return nil, fmt.Errorf("invalid ordering key %q: missing header name", spec)
+		}
+		return func(msg models.RawMessage) string {
+			value, ok := msg.Properties.Headers[name]
+			if !ok || value == nil {
+				return ""
+			}
+			return fmt.Sprint(value)
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid ordering key %q: expected routing_key, message_id, header:NAME or json:PATH", spec)
+	}
+}
+
+// Lane maps a key onto one of n lanes
+func Lane(key string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(n))
+}
+
+// jsonKey returns the value at the given path in a JSON payload, or an empty
+// string when the payload is not JSON or the path does not exist
+func jsonKey(payload string, segments []string) string {
+	var value interface{}
+	if err := json.Unmarshal([]byte(payload), &value); err != nil {
+		return ""
+	}
+
+	for _, segment := range segments {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			value = v[segment]
+		case []interface{}:
+			idx, err := strconv.Atoi(segment)
+			if err != nil || idx < 0 || idx >= len(v) {
+				return ""
+			}
+			value = v[idx]
+		default:
+			return ""
+		}
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		// Objects, arrays and numbers are keyed by their JSON form
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
@@ -0,0 +1,108 @@
+package ordering
+
+import (
+	"testing"
+
+	"github.com/marianozunino/go-publish/internal/models"
+)
+
+func TestParseKey(t *testing.T) {
+	msg := models.RawMessage{
+		RoutingKey: "orders.created",
+		Payload:    `{"order":{"id":"o-1","customer":{"id":42}},"items":[{"sku":"a"},{"sku":"b"}],"tags":["x"]}`,
+		Properties: models.MessageProperties{
+			MessageID: "m-1",
+			Headers: map[string]interface{}{
+				"tenant": "acme",
+				"shard":  float64(3),
+				"empty":  nil,
+			},
+		},
+	}
+
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"routing_key", "orders.created"},
+		{"message_id", "m-1"},
+		{"header:tenant", "acme"},
+		{"header:shard", "3"},
+		{"header:empty", ""},
+		{"header:missing", ""},
+		{"json:order.id", "o-1"},
+		{"json:$.order.id", "o-1"},
+		{"json:order.customer.id", "42"},
+		{"json:order.customer", `{"id":42}`},
+		{"json:items.1.sku", "b"},
+		{"json:items.2.sku", ""},
+		{"json:items.first", ""},
+		{"json:tags", `["x"]`},
+		{"json:order.missing", ""},
+		{"json:order.id.deeper", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			key, err := ParseKey(tt.spec)
+			if err != nil {
+				t.Fatalf("ParseKey(%q) failed: %v", tt.spec, err)
+			}
+			if got := key(msg); got != tt.want {
+				t.Errorf("key = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseKeyNonJSONPayload(t *testing.T) {
+	key, err := ParseKey("json:id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := key(models.RawMessage{Payload: "not json"}); got != "" {
+		t.Errorf("key = %q, want empty", got)
+	}
+}
+
+func TestParseKeyInvalid(t *testing.T) {
+	for _, spec := range []string{"", "routing-key", "header:", "json:", "json:$.", "body"} {
+		if _, err := ParseKey(spec); err == nil {
+			t.Errorf("ParseKey(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestLane(t *testing.T) {
+	// The mapping is FNV-1a and must not change: it is what keeps the
+	// messages of a key on one lane
+	tests := []struct {
+		key  string
+		n    int
+		want int
+	}{
+		{"", 8, 5},
+		{"order-1", 8, 5},
+		{"order-2", 8, 4},
+		{"customer-42", 8, 6},
+		{"customer-42", 1, 0},
+	}
+	for _, tt := range tests {
+		if got := Lane(tt.key, tt.n); got != tt.want {
+			t.Errorf("Lane(%q, %d) = %d, want %d", tt.key, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestLaneInRange(t *testing.T) {
+	for n := 1; n <= 16; n++ {
+		for _, key := range []string{"a", "b", "order-1", "customer-42", "ünïcode"} {
+			lane := Lane(key, n)
+			if lane < 0 || lane >= n {
+				t.Fatalf("Lane(%q, %d) = %d, out of range", key, n, lane)
+			}
+			if again := Lane(key, n); again != lane {
+				t.Fatalf("Lane(%q, %d) changed from %d to %d", key, n, lane, again)
+			}
+		}
+	}
+}
@@ -1,22 +1,156 @@
 package publisher
 
 import (
-	"github.com/marianozunino/go-publish/internal/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
 
-	"github.com/streadway/amqp"
+	"github.com/marianozunino/go-publish/internal/models"
+	"github.com/marianozunino/go-publish/internal/redact"
 )
 
-// PublishMessage publishes a single message to the queue
-func PublishMessage(ch *amqp.Channel, queueName string, msg models.RawMessage) error {
-	return ch.Publish(
-		"",        // exchange (empty for direct to queue)
-		queueName, // routing key (queue name)
-		false,     // mandatory
-		false,     // immediate
-		amqp.Publishing{
-			ContentType:  msg.Properties.ContentType,
-			DeliveryMode: uint8(msg.Properties.DeliveryMode),
-			Priority:     uint8(msg.Properties.Priority),
-			Body:         []byte(msg.Payload),
-		})
+// Publisher sends messages to a destination over an established connection.
+// It offers one or more lanes, each publishing a single message at a time,
+// so the UI can fan messages out and keep them in order per lane.
+// Errors never contain credentials.
+type Publisher interface {
+	// Lanes returns how many messages can be published concurrently
+	Lanes() int
+	// Publish sends msg to the destination on the given lane
+	Publish(lane int, msg models.RawMessage) error
+	// Confirm waits until the target has accepted everything published on
+	// lane. Backends without acknowledgements return nil straight away.
+	Confirm(lane int) error
+	// Health returns a channel receiving an error once the connection is lost.
+	// A graceful Close delivers nothing.
+	Health() <-chan error
+	// Target describes where messages are going
+	Target() Target
+	// Close releases the connection
+	Close() error
+}
+
+// Connector establishes publishers, moving on to the next node of a cluster
+// each time it is asked to connect again
+type Connector interface {
+	Connect() (Publisher, error)
+}
+
+// Preflighter is implemented by publishers that can check the destination
+// before anything is published to it
+type Preflighter interface {
+	// Preflight verifies the publisher may publish and inspects the
+	// destination, warning about anything likely to make count messages go
+	// astray. count is 0 when unknown.
+	Preflight(count int) (*Preflight, error)
+}
+
+// Target describes where a publisher is connected to, for display
+type Target struct {
+	URI         string // with the password masked
+	Host        string // host and port
+	Vhost       string // virtual host or the backend's equivalent, if any
+	Connections int
+}
+
+// Preflight is what is known about the destination before publishing
+type Preflight struct {
+	Exists    bool
+	Messages  int      // messages waiting at the destination
+	Consumers int      // -1 when the backend cannot tell
+	Arguments []string // known settings as key=value pairs; nil when unknown
+	// Policy is the name of the policy in effect, if any, and
+	// PolicyDefinition its settings as key=value pairs; nil when unknown
+	Policy           string
+	PolicyDefinition []string
+	Unknown          string // why the arguments or policy are unknown
+	Warnings         []string
+}
+
+// ConnectionError reports that a publish failed because the connection went
+// away, as opposed to the target refusing the message. Messages failing with
+// it are published again after reconnecting.
+type ConnectionError struct {
+	Err error
+}
+
+func (e *ConnectionError) Error() string { return e.Err.Error() }
+
+func (e *ConnectionError) Unwrap() error { return e.Err }
+
+// IsConnectionError reports whether err means the connection is gone
+func IsConnectionError(err error) bool {
+	var connErr *ConnectionError
+	return errors.As(err, &connErr)
+}
+
+// Rotation connects to the servers of a cluster in turn, moving on to the
+// next one each time it is asked to connect again. The zero value starts at
+// the first server.
+type Rotation struct {
+	mu   sync.Mutex
+	next int // position of the server to try first
+}
+
+// Connect tries every server once, starting after the one connected to last,
+// and returns what connect returns for the first one that accepts it. Errors
+// are redacted; servers names the servers in the error when all fail, e.g.
+// "brokers".
+func (r *Rotation) Connect(uris []string, servers string, connect func(i int, uri string) (Publisher, error)) (Publisher, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var errs []error
+	for attempt := 0; attempt < len(uris); attempt++ {
+		i := (r.next + attempt) % len(uris)
+		uri := uris[i]
+
+		p, err := connect(i, uri)
+		if err == nil {
+			r.next = i + 1
+			return p, nil
+		}
+		err = redact.Error(err, redact.Password(uri))
+		if len(uris) == 1 {
+			return nil, err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", redact.URI(uri), err))
+	}
+
+	return nil, fmt.Errorf("all %d %s failed: %w", len(errs), servers, errors.Join(errs...))
+}
+
+// RedactURIs masks the passwords of all the given URIs in err, for backends
+// connected to several servers at once
+func RedactURIs(err error, uris ...string) error {
+	secrets := make([]string, len(uris))
+	for i, uri := range uris {
+		secrets[i] = redact.Password(uri)
+	}
+	return redact.Error(err, secrets...)
+}
+
+// HeaderString encodes a header value for backends whose headers are text:
+// strings as they are, anything else as JSON
+func HeaderString(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	default:
+		b, _ := json.Marshal(val)
+		return string(b)
+	}
+}
+
+// Scheme returns the lower-case scheme of uri, telling which backend handles it
+func Scheme(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme == "" {
+		return "", fmt.Errorf("invalid URI %q: missing scheme", redact.URI(uri))
+	}
+	return u.Scheme, nil
 }
@@ -0,0 +1,199 @@
+// Package redisstream is the Redis Streams publishing backend
+package redisstream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/marianozunino/go-publish/internal/models"
+	"github.com/marianozunino/go-publish/internal/publisher"
+	"github.com/marianozunino/go-publish/internal/redact"
+	"github.com/marianozunino/go-publish/internal/tlsconfig"
+	"github.com/redis/go-redis/v9"
+)
+
+// connectTimeout bounds the initial check that a server answers
+const connectTimeout = 10 * time.Second
+
+// Options describes the servers to connect to and the stream to add to
+type Options struct {
+	URIs    []string // servers tried one after another: redis://[user:pass@]host:port/db or rediss:// for TLS
+	TLS     tlsconfig.Options
+	Name    string // client name shown by CLIENT LIST
+	Stream  string
+	MaxLen  int64 // trim the stream to about this many entries; 0 keeps everything
+	Workers int   // messages added concurrently
+}
+
+// Dialer connects to the servers in turn, moving on to the next one each
+// time it is asked to connect again
+type Dialer struct {
+	opts     Options
+	clients  []*redis.Options // per server, in the order of the URIs
+	rotation publisher.Rotation
+}
+
+// NewDialer validates the options and returns a Dialer for them
+func NewDialer(opts Options) (*Dialer, error) {
+	if len(opts.URIs) == 0 {
+		return nil, fmt.Errorf("no Redis URI given")
+	}
+	if opts.Stream == "" {
+		return nil, fmt.Errorf("no Redis stream given")
+	}
+	if opts.MaxLen < 0 {
+		return nil, fmt.Errorf("invalid stream max length %d", opts.MaxLen)
+	}
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+
+	clients := make([]*redis.Options, len(opts.URIs))
+	for i, uri := range opts.URIs {
+		copts, err := redis.ParseURL(uri)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Redis URI %q: %w", redact.URI(uri), redact.Error(err, redact.Password(uri)))
+		}
+
+		if copts.TLSConfig != nil {
+			tlsCfg, err := opts.TLS.Build()
+			if err != nil {
+				return nil, err
+			}
+			if tlsCfg.ServerName == "" {
+				tlsCfg.ServerName = copts.TLSConfig.ServerName
+			}
+			copts.TLSConfig = tlsCfg
+		} else if opts.TLS.IsSet() {
+			return nil, fmt.Errorf("TLS options require a rediss:// URI")
+		}
+
+		// Client names may not contain spaces
+		copts.ClientName = strings.ReplaceAll(opts.Name, " ", "-")
+		copts.PoolSize = opts.Workers
+		clients[i] = copts
+	}
+
+	return &Dialer{opts: opts, clients: clients}, nil
+}
+
+// Connect tries every server once, starting after the one connected to
+// last, and returns a producer on the first one that answers
+func (d *Dialer) Connect() (publisher.Publisher, error) {
+	return d.rotation.Connect(d.opts.URIs, "servers", func(i int, uri string) (publisher.Publisher, error) {
+		client := redis.NewClient(d.clients[i])
+		ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+		defer cancel()
+		if err := client.Ping(ctx).Err(); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+		}
+		return &Producer{client: client, dialer: d, uri: uri}, nil
+	})
+}
+
+// Producer adds messages as entries of the stream
+type Producer struct {
+	client *redis.Client
+	dialer *Dialer
+	uri    string
+}
+
+// Lanes returns the number of workers; the client is safe for concurrent use
+func (p *Producer) Lanes() int {
+	return p.dialer.opts.Workers
+}
+
+// Publish adds msg to the stream; the reply carrying the entry ID is the
+// acknowledgement
+func (p *Producer) Publish(lane int, msg models.RawMessage) error {
+	opts := p.dialer.opts
+	err := p.client.XAdd(context.Background(), &redis.XAddArgs{
+		Stream: opts.Stream,
+		MaxLen: opts.MaxLen,
+		Approx: true,
+		Values: fields(msg),
+	}).Err()
+	if err == nil {
+		return nil
+	}
+
+	if isConnectionError(err) {
+		return &publisher.ConnectionError{Err: redact.Error(err, redact.Password(p.uri))}
+	}
+	return redact.Error(err, redact.Password(p.uri))
+}
+
+// Confirm returns nil: Publish already waited for the reply
+func (p *Producer) Confirm(lane int) error {
+	return nil
+}
+
+// Health returns a channel that never fires, as the client redials by
+// itself; a lost server shows as failing publishes instead
+func (p *Producer) Health() <-chan error {
+	return make(chan error)
+}
+
+// Target describes the server
+func (p *Producer) Target() publisher.Target {
+	return publisher.Target{
+		URI:         redact.URI(p.uri),
+		Host:        p.client.Options().Addr,
+		Vhost:       "db " + strconv.Itoa(p.client.Options().DB),
+		Connections: 1,
+	}
+}
+
+// Close closes the client and its connections
+func (p *Producer) Close() error {
+	return p.client.Close()
+}
+
+// fields maps a message to the fields of a stream entry: the payload, the
+// routing key and each property that is set, with the headers as JSON
+func fields(msg models.RawMessage) []interface{} {
+	values := []interface{}{"payload", msg.Payload}
+	add := func(name, value string) {
+		if value != "" {
+			values = append(values, name, value)
+		}
+	}
+
+	props := msg.Properties
+	add("exchange", msg.Exchange)
+	add("routing_key", msg.RoutingKey)
+	add("content_type", props.ContentType)
+	add("message_id", props.MessageID)
+	if props.Timestamp != 0 {
+		add("timestamp", strconv.FormatInt(props.Timestamp, 10))
+	}
+	if props.Priority != 0 {
+		add("priority", strconv.Itoa(props.Priority))
+	}
+	if props.DeliveryMode != 0 {
+		add("delivery_mode", strconv.Itoa(props.DeliveryMode))
+	}
+	if len(props.Headers) > 0 {
+		headers, _ := json.Marshal(props.Headers)
+		add("headers", string(headers))
+	}
+	return values
+}
+
+// isConnectionError reports whether err means the server could not be
+// reached, as opposed to the server refusing the command
+func isConnectionError(err error) bool {
+	if errors.Is(err, redis.ErrClosed) || errors.Is(err, io.EOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,170 @@
+// Package sink writes messages to a file or stdout instead of a broker, so a
+// replay can produce a rewritten dump
+package sink
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"sync"
+
+	"github.com/marianozunino/go-publish/internal/models"
+	"github.com/marianozunino/go-publish/internal/publisher"
+)
+
+// Stdout is the URI writing to standard output
+const Stdout = "-"
+
+// Format is how messages are written
+type Format string
+
+const (
+	FormatNDJSON Format = "ndjson" // one message per line, as read by models.ParseMessageFile
+	FormatBody   Format = "body"   // the payload of each message, one per line
+)
+
+// Options describes where and how messages are written
+type Options struct {
+	URI    string // file://path or - for stdout
+	Format Format
+}
+
+// Dialer opens the output
+type Dialer struct {
+	opts Options
+	path string   // empty for stdout
+	file *os.File // created by NewDialer, until the first Connect takes it
+}
+
+// NewDialer validates the options and returns a Dialer for them. The output
+// file is created right away; an existing file is left alone, as it may be
+// the very input the messages come from.
+func NewDialer(opts Options) (*Dialer, error) {
+	switch opts.Format {
+	case "":
+		opts.Format = FormatNDJSON
+	case FormatNDJSON, FormatBody:
+	default:
+		return nil, fmt.Errorf("invalid output format %q (expected ndjson or body)", opts.Format)
+	}
+
+	d := &Dialer{opts: opts}
+	if opts.URI == Stdout {
+		return d, nil
+	}
+
+	u, err := url.Parse(opts.URI)
+	if err != nil || u.Scheme != "file" {
+		return nil, fmt.Errorf("invalid output %q (expected file://path or -)", opts.URI)
+	}
+	// file://out.ndjson is relative, file:///tmp/out.ndjson absolute
+	d.path = u.Host + u.Path
+	if d.path == "" {
+		return nil, fmt.Errorf("invalid output %q: missing path", opts.URI)
+	}
+
+	d.file, err = os.OpenFile(d.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if errors.Is(err, fs.ErrExist) {
+		return nil, fmt.Errorf("file exists: %s; choose another output", d.path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output file: %w", err)
+	}
+	return d, nil
+}
+
+// Connect hands out the output file created by NewDialer. Connecting again,
+// e.g. after the previous sink was closed, appends to it.
+func (d *Dialer) Connect() (publisher.Publisher, error) {
+	if d.path == "" {
+		return &Sink{dialer: d, out: bufio.NewWriter(os.Stdout)}, nil
+	}
+
+	file := d.file
+	d.file = nil
+	if file == nil {
+		var err error
+		if file, err = os.OpenFile(d.path, os.O_WRONLY|os.O_APPEND, 0); err != nil {
+			return nil, fmt.Errorf("failed to open output file: %w", err)
+		}
+	}
+	return &Sink{dialer: d, file: file, out: bufio.NewWriter(file)}, nil
+}
+
+// Sink writes messages one per line
+type Sink struct {
+	dialer *Dialer
+	file   *os.File // nil for stdout
+
+	mu  sync.Mutex
+	out *bufio.Writer
+}
+
+// Lanes returns 1, so the output keeps the order of the input
+func (s *Sink) Lanes() int {
+	return 1
+}
+
+// Publish writes msg in the configured format
+func (s *Sink) Publish(lane int, msg models.RawMessage) error {
+	var line []byte
+	if s.dialer.opts.Format == FormatBody {
+		line = []byte(msg.Payload)
+	} else {
+		var err error
+		if line, err = models.EncodeMessage(msg); err != nil {
+			return fmt.Errorf("failed to encode message: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.out.Write(line)
+	s.out.WriteByte('\n')
+	// Flush every message, so the output is complete up to the last one
+	// counted as sent even if the replay is interrupted
+	if err := s.out.Flush(); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	return nil
+}
+
+// Confirm returns nil: Publish already wrote the message
+func (s *Sink) Confirm(lane int) error {
+	return nil
+}
+
+// Health returns a channel that never fires, as there is no connection
+func (s *Sink) Health() <-chan error {
+	return make(chan error)
+}
+
+// Target describes the output
+func (s *Sink) Target() publisher.Target {
+	name := s.dialer.path
+	if name == "" {
+		name = "stdout"
+	}
+	return publisher.Target{
+		URI:         s.dialer.opts.URI,
+		Host:        name,
+		Connections: 1,
+	}
+}
+
+// Close flushes the output and closes the file
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.out.Flush()
+	if s.file != nil {
+		if closeErr := s.file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,90 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// Options describes how to verify the server and authenticate the client
+type Options struct {
+	CAFile             string // PEM bundle of CAs trusted in addition to the system pool
+	CertFile           string // PEM client certificate for mutual TLS
+	KeyFile            string // PEM private key of the client certificate
+	ServerName         string // overrides the host name the certificate is verified against
+	MinVersion         string // 1.0, 1.1, 1.2 or 1.3
+	InsecureSkipVerify bool
+}
+
+// IsSet returns true if any certificate or protocol option is given.
+// InsecureSkipVerify does not count, as it is harmless without TLS.
+func (o Options) IsSet() bool {
+	o.InsecureSkipVerify = false
+	return o != Options{}
+}
+
+// HasClientCert returns true if a client certificate is configured
+func (o Options) HasClientCert() bool {
+	return o.CertFile != ""
+}
+
+// Build creates a tls.Config from the options
+func (o Options) Build() (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName:         o.ServerName,
+		InsecureSkipVerify: o.InsecureSkipVerify,
+	}
+
+	if o.MinVersion != "" {
+		version, err := parseVersion(o.MinVersion)
+		if err != nil {
+			return nil, err
+		}
+		cfg.MinVersion = version
+	}
+
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", o.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if o.CertFile != "" || o.KeyFile != "" {
+		if o.CertFile == "" || o.KeyFile == "" {
+			return nil, fmt.Errorf("client certificate and key must be given together")
+		}
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// parseVersion maps a version string to its crypto/tls constant
+func parseVersion(v string) (uint16, error) {
+	switch v {
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported TLS version %q (expected 1.0, 1.1, 1.2 or 1.3)", v)
+	}
+}
@@ -0,0 +1,232 @@
+package ui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// newJumpInput creates the field the message number to jump to is typed into
+func newJumpInput() textinput.Model {
+	input := textinput.New()
+	input.Placeholder = "message number"
+	input.Prompt = "> "
+	input.CharLimit = 12
+	return input
+}
+
+// startJump opens the prompt for the message number to jump to, while paused
+func (m Model) startJump() (tea.Model, tea.Cmd) {
+	if !m.UI.IsPaused {
+		return m, nil
+	}
+	m.UI.Jumping = true
+	m.UI.JumpError = ""
+	m.UI.JumpInput.SetValue("")
+	return m, m.UI.JumpInput.Focus()
+}
+
+// handleJumpKey edits the typed message number, jumping to it on enter
+func (m Model) handleJumpKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		n, err := strconv.Atoi(strings.TrimSpace(m.UI.JumpInput.Value()))
+		if err != nil || n < 1 || (!m.Publisher.Unbounded && n > m.Publisher.TotalMessages) {
+			if m.Publisher.Unbounded {
+				m.UI.JumpError = "Enter a message number from 1"
+			} else {
+				m.UI.JumpError = fmt.Sprintf("Enter a message number from 1 to %d", m.Publisher.TotalMessages)
+			}
+			return m, nil
+		}
+		m.UI.Jumping = false
+		m.UI.JumpInput.Blur()
+		return m.jumpTo(n - 1), nil
+	case "esc":
+		m.UI.Jumping = false
+		m.UI.JumpInput.Blur()
+		return m, nil
+	case "ctrl+c":
+		return m, tea.Quit
+	}
+
+	var cmd tea.Cmd
+	m.UI.JumpInput, cmd = m.UI.JumpInput.Update(msg)
+	m.UI.JumpError = ""
+	return m, cmd
+}
+
+// jumpTo moves the cursor to idx. Messages read ahead but not started yet
+// are dropped, and the ones jumped over count as skipped; jumping back
+// publishes messages again.
+func (m Model) jumpTo(idx int) Model {
+	for _, d := range m.Publisher.Retry {
+		if d.index < idx {
+			m.Stats.SkippedCount++
+		}
+	}
+	m.Publisher.Retry = nil
+	for lane := range m.Publisher.Lanes {
+		for _, d := range m.Publisher.Lanes[lane].Queue {
+			if d.index < idx {
+				m.Stats.SkippedCount++
+			}
+		}
+		m.Publisher.Lanes[lane].Queue = nil
+	}
+
+	if idx > m.Publisher.CurrentIndex {
+		m.Stats.SkippedCount += idx - m.Publisher.CurrentIndex
+	}
+	m.Publisher.CurrentIndex = idx
+	m.UI.PreviewOffset = 0
+
+	// Original timing carries on from the target, instead of waiting out or
+	// catching up on the recording in between
+	if m.Timing.Enabled {
+		m.Timing.AnchorOffset = m.Timing.Offsets[idx]
+		m.Timing.Anchor = time.Now()
+	}
+	return m
+}
+
+// skip drops the next message to be published, while paused, the one the
+// preview marks as next
+func (m Model) skip() (tea.Model, tea.Cmd) {
+	if !m.UI.IsPaused {
+		return m, nil
+	}
+
+	var taken bool
+	if m, taken = m.takeReadAhead(m.nextPending()); !taken {
+		if !m.Publisher.Unbounded && m.Publisher.CurrentIndex >= m.Publisher.TotalMessages {
+			return m, nil
+		}
+		m.Publisher.CurrentIndex++
+	}
+	m.Stats.SkippedCount++
+	return m, nil
+}
+
+// step publishes exactly one more message while paused
+func (m Model) step() (tea.Model, tea.Cmd) {
+	if !m.UI.IsPaused {
+		return m, nil
+	}
+	m.Publisher.Steps++
+	return m.dispatch()
+}
+
+// toggleExclude marks the message at the top of the preview, or the next
+// one when the preview is hidden, to be skipped once the cursor gets to it,
+// or unmarks it again. A message already read ahead is taken off its lane
+// at once and for good, the way skip drops it; messages handed out are left
+// alone.
+func (m Model) toggleExclude() (tea.Model, tea.Cmd) {
+	idx := m.nextPending()
+	if m.UI.Preview {
+		idx += m.UI.PreviewOffset
+	}
+	if !m.Publisher.Unbounded && idx >= m.Publisher.TotalMessages {
+		return m, nil
+	}
+	if m.Publisher.Excluded == nil {
+		m.Publisher.Excluded = make(map[int]bool)
+	}
+
+	if idx < m.Publisher.CurrentIndex {
+		var taken bool
+		if m, taken = m.takeReadAhead(idx); taken {
+			m.Publisher.Excluded[idx] = true
+			m.Stats.SkippedCount++
+		}
+		return m, nil
+	}
+
+	if m.Publisher.Excluded[idx] {
+		delete(m.Publisher.Excluded, idx)
+	} else {
+		m.Publisher.Excluded[idx] = true
+	}
+	return m, nil
+}
+
+// takeReadAhead drops the message at idx if it was read ahead, from its lane
+// queue or the messages waiting to be published again
+func (m Model) takeReadAhead(idx int) (Model, bool) {
+	for i, d := range m.Publisher.Retry {
+		if d.index == idx {
+			m.Publisher.Retry = append(m.Publisher.Retry[:i], m.Publisher.Retry[i+1:]...)
+			return m, true
+		}
+	}
+	for lane := range m.Publisher.Lanes {
+		queue := m.Publisher.Lanes[lane].Queue
+		for i, d := range queue {
+			if d.index == idx {
+				m.Publisher.Lanes[lane].Queue = append(queue[:i], queue[i+1:]...)
+				return m, true
+			}
+		}
+	}
+	return m, false
+}
+
+// nextPending returns the index of the message to be published next: one
+// waiting to be published again first, then the oldest one read ahead onto a
+// lane, then the one at the cursor
+func (m Model) nextPending() int {
+	if len(m.Publisher.Retry) > 0 {
+		return m.Publisher.Retry[0].index
+	}
+	if lane := m.oldestQueuedLane(false); lane >= 0 {
+		return m.Publisher.Lanes[lane].Queue[0].index
+	}
+	return m.Publisher.CurrentIndex
+}
+
+// readAhead returns the messages read past the cursor but not handed to a
+// lane yet, by index: those queued on a lane by their ordering key or
+// waiting to be published again
+func (m Model) readAhead() map[int]delivery {
+	read := make(map[int]delivery, m.queued())
+	for _, d := range m.Publisher.Retry {
+		read[d.index] = d
+	}
+	for _, lane := range m.Publisher.Lanes {
+		for _, d := range lane.Queue {
+			read[d.index] = d
+		}
+	}
+	return read
+}
+
+// oldestQueuedLane returns the lane, idle when idleOnly, whose queue starts
+// with the lowest message index, or -1 when no such lane has messages queued
+func (m Model) oldestQueuedLane(idleOnly bool) int {
+	oldest := -1
+	for lane, state := range m.Publisher.Lanes {
+		if len(state.Queue) == 0 || (idleOnly && state.Busy) {
+			continue
+		}
+		if oldest < 0 || state.Queue[0].index < m.Publisher.Lanes[oldest].Queue[0].index {
+			oldest = lane
+		}
+	}
+	return oldest
+}
+
+// position returns how far through the messages the replay is: those handed
+// out and no longer pending, whether published, failed or skipped
+func (m Model) position() int {
+	n := m.Publisher.CurrentIndex - m.Publisher.InFlight - m.queued()
+	// Messages still in flight after jumping back were handed out past the cursor
+	if n < 0 {
+		n = 0
+	}
+	return n
+}
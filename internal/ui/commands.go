@@ -1,9 +1,11 @@
 package ui
 
 import (
+	"context"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/marianozunino/go-publish/internal/models"
 	"github.com/marianozunino/go-publish/internal/publisher"
 )
 
@@ -14,62 +16,106 @@ func tickCmd() tea.Cmd {
 	})
 }
 
-// Command to publish the next message
-func publishMessageCmd(m Model) tea.Cmd {
+// Command to publish a message on its lane. Cancelling ctx cuts short the
+// wait for the rate limiter.
+func publishMessageCmd(ctx context.Context, m Model, d delivery) tea.Cmd {
+	conn := m.Publisher.Conn
+	generation := m.Publisher.Generation
+	limiter := m.Publisher.Limiter
+
 	return func() tea.Msg {
-		if m.UI.IsPaused || m.IsComplete() {
-			return nil
+		// Wait for a token when rate limited, otherwise for the send slot
+		// handed out for the fixed delay; original timing is enforced by
+		// dispatch instead
+		if limiter != nil {
+			if err := limiter.Wait(ctx); err != nil {
+				if ctx.Err() != nil {
+					return publishResultMsg{delivery: d, generation: generation, cancelled: true}
+				}
+				return publishResultMsg{delivery: d, generation: generation, success: false, err: err}
+			}
+		} else if wait := time.Until(d.notBefore); wait > 0 {
+			time.Sleep(wait)
 		}
 
-		// Add delay between messages (only if greater than 0)
-		if m.Publisher.Delay > 0 {
-			time.Sleep(m.Publisher.Delay)
+		err := conn.Publish(d.lane, d.msg)
+		if err == nil {
+			err = conn.Confirm(d.lane)
 		}
 
-		currentIdx := m.Publisher.CurrentIndex
-		msg := m.Publisher.Messages[currentIdx]
-		err := publisher.PublishMessage(m.Publisher.Channel, m.Publisher.QueueName, msg)
-
 		return publishResultMsg{
-			success: err == nil,
-			err:     err,
+			delivery:   d,
+			generation: generation,
+			success:    err == nil,
+			err:        err,
 		}
 	}
 }
 
-// Command to adjust delay up
-func increaseDelayCmd(m Model) tea.Cmd {
+// Command waiting for the connection to drop
+func watchConnectionCmd(conn publisher.Publisher, generation int) tea.Cmd {
+	lost := conn.Health()
 	return func() tea.Msg {
-		// Increase delay by 5ms
-		return nil
+		err := <-lost
+		return connectionLostMsg{generation: generation, err: err}
 	}
 }
 
-// Command to adjust delay down
-func decreaseDelayCmd(m Model) tea.Cmd {
+// reconnectBackoff is how long to wait before trying all nodes again
+const reconnectBackoff = 2 * time.Second
+
+// Command closing what is left of the old connection and connecting to the
+// next node, after waiting when the previous attempt failed
+func reconnectCmd(connector publisher.Connector, old publisher.Publisher, wait time.Duration) tea.Cmd {
 	return func() tea.Msg {
-		// Decrease delay by 5ms
-		return nil
+		if old != nil {
+			old.Close()
+		}
+		time.Sleep(wait)
+		conn, err := connector.Connect()
+		return reconnectedMsg{conn: conn, err: err}
+	}
+}
+
+// maxScheduleWait bounds how long a schedule check sleeps, so speed changes
+// and pauses take effect promptly even across long gaps between messages
+const maxScheduleWait = 100 * time.Millisecond
+
+// Command to check again when the next message is due under original timing
+func scheduleCmd(m Model) tea.Cmd {
+	wait := time.Until(m.scheduledAt(m.Publisher.CurrentIndex))
+	if wait > maxScheduleWait {
+		wait = maxScheduleWait
 	}
+	if wait < time.Millisecond {
+		wait = time.Millisecond
+	}
+
+	return tea.Tick(wait, func(time.Time) tea.Msg {
+		return scheduleMsg{}
+	})
 }
 
-// Command to toggle pause state
-func togglePauseCmd(m Model) tea.Cmd {
+// Command writing failed messages to a file, in the format of the input file
+func writeFailedCmd(path string, msgs []models.RawMessage) tea.Cmd {
 	return func() tea.Msg {
-		// Toggle pause state and handle timing
-		if m.UI.IsPaused {
-			// We're resuming - continue publishing
-			return publishMessageCmd(m)()
-		}
+		err := models.WriteMessageFile(path, msgs)
+		return writtenMsg{path: path, count: len(msgs), err: err}
+	}
+}
+
+// Command to adjust delay up
+func increaseDelayCmd(m Model) tea.Cmd {
+	return func() tea.Msg {
+		// Increase delay by 5ms
 		return nil
 	}
 }
 
-// Command batch for all regular UI updates
-func regularUpdateCmds(m Model) tea.Cmd {
-	cmds := []tea.Cmd{tickCmd()}
-	if !m.UI.IsPaused && !m.IsComplete() {
-		cmds = append(cmds, publishMessageCmd(m))
+// Command to adjust delay down
+func decreaseDelayCmd(m Model) tea.Cmd {
+	return func() tea.Msg {
+		// Decrease delay by 5ms
+		return nil
 	}
-	return tea.Batch(cmds...)
 }
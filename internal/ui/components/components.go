@@ -2,15 +2,42 @@ package components
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/lipgloss"
 )
 
 // RenderQueueInfo creates the queue information box
-func RenderQueueInfo(queueName string, insecureTLS bool, styles map[string]lipgloss.Style) string {
+func RenderQueueInfo(
+	node string,
+	reconnecting bool,
+	failovers int,
+	queueName string,
+	workers int,
+	connections int,
+	ordered bool,
+	insecureTLS bool,
+	styles map[string]lipgloss.Style,
+) string {
 	queueInfo := styles["subtitle"].Render("Queue Connection")
 	queueInfo += "\n" + styles["info"].Render(queueName)
+	if node != "" {
+		queueInfo += "\n" + styles["dimmed"].Render(node)
+	}
+	if reconnecting {
+		queueInfo += "\n" + styles["warning"].Render("🔌 Connection lost, failing over to the next node…")
+	} else if failovers > 0 {
+		queueInfo += "\n" + styles["dimmed"].Render(fmt.Sprintf("Failed over %d time(s)", failovers))
+	}
+
+	if workers > 1 {
+		workerInfo := fmt.Sprintf("%d workers across %d connection(s)", workers, connections)
+		if ordered {
+			workerInfo += ", ordered by key"
+		}
+		queueInfo += "\n" + styles["dimmed"].Render(workerInfo)
+	}
 
 	if insecureTLS {
 		queueInfo += "\n" + styles["warning"].Render("⚠️  Insecure mode: TLS certificate validation disabled")
@@ -23,10 +50,21 @@ func RenderQueueInfo(queueName string, insecureTLS bool, styles map[string]lipgl
 func RenderProgressSection(
 	current int,
 	total int,
+	retryPass int,
 	progressBar string,
 	styles map[string]lipgloss.Style,
 ) string {
-	progressSection := styles["subtitle"].Render("Progress")
+	title := "Progress"
+	if retryPass > 0 {
+		title = fmt.Sprintf("Progress (retry pass %d, failed messages only)", retryPass)
+	}
+	progressSection := styles["subtitle"].Render(title)
+
+	// Without a total (generating indefinitely) there is nothing to measure against
+	if total <= 0 {
+		progressSection += "\n" + styles["info"].Render(fmt.Sprintf("%d messages (running indefinitely)", current))
+		return styles["box"].Render(progressSection)
+	}
 
 	// Add the progress bar
 	progressSection += "\n" + progressBar
@@ -40,13 +78,146 @@ func RenderProgressSection(
 	return styles["box"].Render(progressSection)
 }
 
+// LaneProgress summarizes one publishing lane
+type LaneProgress struct {
+	Busy   bool
+	Done   int
+	Queued int
+}
+
+// maxLanesShown caps how many lanes get their own line
+const maxLanesShown = 16
+
+// RenderLanesSection creates the per-lane progress section. Bars are relative
+// to the busiest lane, so an uneven key distribution stands out.
+func RenderLanesSection(lanes []LaneProgress, barWidth int, styles map[string]lipgloss.Style) string {
+	if len(lanes) < 2 {
+		return ""
+	}
+
+	lanesSection := styles["subtitle"].Render("Lanes")
+
+	most := 1
+	for _, lane := range lanes {
+		if lane.Done > most {
+			most = lane.Done
+		}
+	}
+	if barWidth < 10 {
+		barWidth = 10
+	}
+
+	for i, lane := range lanes {
+		if i == maxLanesShown {
+			lanesSection += "\n" + styles["dimmed"].Render(fmt.Sprintf("… and %d more", len(lanes)-maxLanesShown))
+			break
+		}
+
+		status := styles["dimmed"].Render("○")
+		if lane.Busy {
+			status = styles["running"].Render("●")
+		}
+		filled := lane.Done * barWidth / most
+		bar := strings.Repeat("█", filled) + strings.Repeat("░", barWidth-filled)
+
+		lanesSection += "\n" + fmt.Sprintf("%s %s %s %s",
+			status,
+			styles["info"].Render(fmt.Sprintf("Lane %-3d", i+1)),
+			styles["dimmed"].Render(bar),
+			styles["info"].Render(fmt.Sprintf("%d done, %d queued", lane.Done, lane.Queued)))
+	}
+
+	return styles["box"].Render(lanesSection)
+}
+
+// MessagePreview summarizes one message for the preview pane
+type MessagePreview struct {
+	Position   int  // one-based
+	Total      int  // 0 when unbounded
+	Next       bool // the next message to be published
+	Sent       bool // already handed out for publishing
+	Excluded   bool // marked to be skipped
+	Exchange   string
+	RoutingKey string
+	Properties []string
+	Body       []string
+	MoreLines  int    // body lines left out
+	Error      string // why the message could not be read
+}
+
+// RenderPreviewSection creates the pane showing the messages around the
+// cursor. generated notes that rendering them again gives different random
+// values than were, or will be, published.
+func RenderPreviewSection(previews []MessagePreview, generated bool, paused bool, styles map[string]lipgloss.Style) string {
+	section := styles["subtitle"].Render("Message Preview")
+	if generated {
+		section += "\n" + styles["dimmed"].Render("Rendered for the preview; random values differ from those published")
+	}
+	if len(previews) == 0 {
+		section += "\n" + styles["dimmed"].Render("No more messages.")
+	}
+
+	for i, p := range previews {
+		if i > 0 {
+			section += "\n"
+		}
+
+		position := fmt.Sprintf("#%d", p.Position)
+		if p.Total > 0 {
+			position = fmt.Sprintf("#%d/%d", p.Position, p.Total)
+		}
+		switch {
+		case p.Excluded:
+			position = styles["warning"].Render("✂ " + position + " excluded")
+		case p.Next:
+			position = styles["running"].Render("▶ "+position) + styles["dimmed"].Render(" next")
+		case p.Sent:
+			position = styles["dimmed"].Render("  " + position + " sent")
+		default:
+			position = styles["info"].Render("  " + position)
+		}
+		section += "\n" + position
+
+		if p.Error != "" {
+			section += "\n" + styles["error"].Render("    "+p.Error)
+			continue
+		}
+
+		route := "routing key: " + p.RoutingKey
+		if p.RoutingKey == "" {
+			route = "routing key: (none)"
+		}
+		if p.Exchange != "" {
+			route = "exchange: " + p.Exchange + ", " + route
+		}
+		section += "\n" + styles["dimmed"].Render("    "+route)
+		if len(p.Properties) > 0 {
+			section += "\n" + styles["dimmed"].Render("    "+strings.Join(p.Properties, ", "))
+		}
+		for _, line := range p.Body {
+			section += "\n    " + styles["info"].Render(line)
+		}
+		if p.MoreLines > 0 {
+			section += "\n" + styles["dimmed"].Render(fmt.Sprintf("    … %d more line(s)", p.MoreLines))
+		}
+	}
+
+	if !paused {
+		section += "\n\n" + styles["dimmed"].Render("Pause to scroll")
+	}
+
+	return styles["box"].Render(section)
+}
+
 // RenderStatsSection creates the statistics section
 func RenderStatsSection(
 	isPaused bool,
 	successCount int,
 	errorCount int,
-	delay time.Duration,
+	skippedCount int,
+	pacing string,
 	msgPerSec float64,
+	recentRate float64,
 	eta time.Duration,
 	elapsed time.Duration,
 	contentWidth int,
@@ -65,11 +236,14 @@ func RenderStatsSection(
 	// Success and error counts
 	successLine := fmt.Sprintf("✅ Success: %d", successCount)
 	errorLine := fmt.Sprintf("❌ Errors: %d", errorCount)
+	skippedLine := fmt.Sprintf("⏭️  Skipped: %d", skippedCount)
 
 	// ETA line (only show meaningful ETA when not paused)
 	etaLine := "⏰ ETA: "
 	if isPaused {
 		etaLine += "Paused"
+	} else if eta < 0 {
+		etaLine += "∞"
 	} else {
 		etaLine += eta.Round(time.Second).String()
 	}
@@ -85,10 +259,16 @@ func RenderStatsSection(
 						return styles["error"].Render(errorLine)
 					}
 					return styles["info"].Render(errorLine)
+				})()+"\n"+
+				(func() string {
+					if skippedCount > 0 {
+						return styles["warning"].Render(skippedLine)
+					}
+					return styles["info"].Render(skippedLine)
 				})()),
 		lipgloss.NewStyle().Width(contentWidth/2-4).Render(
-			styles["info"].Render(fmt.Sprintf("⏱️  Delay: %s", delay.Round(time.Millisecond)))+"\n"+
-				styles["info"].Render(fmt.Sprintf("🚀 Speed: %.1f msg/sec", msgPerSec))+"\n"+
+			styles["info"].Render(pacing)+"\n"+
+				styles["info"].Render(fmt.Sprintf("🚀 Speed: %.1f msg/sec (now %.1f)", msgPerSec, recentRate))+"\n"+
 				styles["info"].Render(etaLine)),
 	)
 
@@ -100,28 +280,345 @@ func RenderStatsSection(
 	return styles["box"].Render(statsSection)
 }
 
-// RenderErrorBox creates an error box if there's an error
-func RenderErrorBox(lastError string, styles map[string]lipgloss.Style) string {
+// RenderErrorBox creates an error box if there's an error, pointing to the
+// error log when it holds failures
+func RenderErrorBox(lastError string, logged int, styles map[string]lipgloss.Style) string {
 	if lastError == "" {
 		return ""
 	}
 
 	errorBox := styles["subtitle"].Render("Last Error")
 	errorBox += "\n" + styles["error"].Render(lastError)
+	if logged > 0 {
+		errorBox += "\n" + styles["dimmed"].Render(fmt.Sprintf("Press e to list all %d failure(s)", logged))
+	}
 	return styles["box"].Copy().BorderForeground(styles["error"].GetForeground()).Render(errorBox)
 }
 
+// ErrorGroup is the failures sharing an error text
+type ErrorGroup struct {
+	Error    string
+	Failures []ErrorEntry
+}
+
+// ErrorEntry is one failed message in the error log
+type ErrorEntry struct {
+	At         time.Time
+	Position   int // one-based
+	RoutingKey string
+	Retryable  bool
+}
+
+// RenderErrorLog creates the error log, listing the failures of each group
+// under its error text and count. selected counts failures across groups;
+// only the maxLines lines around it are shown.
+func RenderErrorLog(
+	groups []ErrorGroup,
+	errorCount int,
+	selected int,
+	maxLines int,
+	width int,
+	styles map[string]lipgloss.Style,
+) string {
+	section := styles["subtitle"].Render("Error Log")
+
+	logged := 0
+	for _, group := range groups {
+		logged += len(group.Failures)
+	}
+	if logged == 0 {
+		section += "\n" + styles["dimmed"].Render("No failures.")
+		return styles["box"].Render(section)
+	}
+	summary := fmt.Sprintf("%d failure(s), %d distinct error(s)", logged, len(groups))
+	if errorCount > logged {
+		summary += fmt.Sprintf("; only the last %d of %d are kept", logged, errorCount)
+	}
+	section += "\n" + styles["dimmed"].Render(summary) + "\n"
+
+	var lines []string
+	selectedLine, entry := 0, 0
+	for _, group := range groups {
+		lines = append(lines, styles["error"].Render(truncate(fmt.Sprintf("%d× %s", len(group.Failures), group.Error), width)))
+		for _, f := range group.Failures {
+			marker := "  "
+			if entry == selected {
+				marker = "› "
+				selectedLine = len(lines)
+			}
+			routingKey := f.RoutingKey
+			if routingKey == "" {
+				routingKey = "(no routing key)"
+			}
+			line := fmt.Sprintf("%s#%-7d %s  %s", marker, f.Position, f.At.Format("15:04:05"), routingKey)
+			if !f.Retryable {
+				line += " (not retryable)"
+			}
+			line = truncate(line, width)
+			if entry == selected {
+				lines = append(lines, styles["info"].Render(line))
+			} else {
+				lines = append(lines, styles["dimmed"].Render(line))
+			}
+			entry++
+		}
+	}
+
+	// Keep the selected failure in view
+	start := 0
+	if len(lines) > maxLines {
+		start = selectedLine - maxLines/2
+		if start < 0 {
+			start = 0
+		}
+		if start > len(lines)-maxLines {
+			start = len(lines) - maxLines
+		}
+		lines = lines[start : start+maxLines]
+	}
+	section += "\n" + strings.Join(lines, "\n")
+
+	return styles["box"].Render(section)
+}
+
+// truncate shortens s to at most width characters
+func truncate(s string, width int) string {
+	if runes := []rune(s); len(runes) > width {
+		return string(runes[:width-1]) + "…"
+	}
+	return s
+}
+
 // RenderControlsBox creates the controls box
-func RenderControlsBox(styles map[string]lipgloss.Style) string {
+func RenderControlsBox(originalTiming bool, previewing bool, paused bool, styles map[string]lipgloss.Style) string {
+	increase, decrease := " Increase Delay | ", " Decrease Delay | "
+	if originalTiming {
+		increase, decrease = " Faster | ", " Slower | "
+	}
+	preview := " Preview | "
+	if previewing && paused {
+		preview += styles["dimmed"].Render("↑/↓") + " Scroll | "
+	}
+
 	controlsText := styles["subtitle"].Render("Controls") + "\n" +
 		styles["dimmed"].Render("SPACE") + " Pause/Resume | " +
-		styles["dimmed"].Render("+") + " Increase Delay | " +
-		styles["dimmed"].Render("-") + " Decrease Delay | " +
+		styles["dimmed"].Render("+") + increase +
+		styles["dimmed"].Render("-") + decrease +
+		styles["dimmed"].Render("p") + preview +
 		styles["dimmed"].Render("q") + " Quit"
+	if paused {
+		controlsText += "\n" +
+			styles["dimmed"].Render("n") + " Send One | " +
+			styles["dimmed"].Render("s") + " Skip | " +
+			styles["dimmed"].Render("g") + " Jump To | " +
+			styles["dimmed"].Render("x") + " Exclude"
+	}
 
 	return styles["controlsBox"].Render(controlsText)
 }
 
+// RenderErrorLogControls creates the controls box of the error log
+func RenderErrorLogControls(styles map[string]lipgloss.Style) string {
+	controlsText := styles["subtitle"].Render("Controls") + "\n" +
+		styles["dimmed"].Render("↑/↓") + " Select | " +
+		styles["dimmed"].Render("r") + " Retry Selected | " +
+		styles["dimmed"].Render("R") + " Retry All | " +
+		styles["dimmed"].Render("e/ESC") + " Back | " +
+		styles["dimmed"].Render("q") + " Quit"
+
+	return styles["controlsBox"].Render(controlsText)
+}
+
+// RenderSummary creates the summary shown once a run is complete. retryable
+// is how many failed messages can be published again, logged how many
+// failures the error log still holds.
+func RenderSummary(
+	retryPass int,
+	successCount int,
+	errorCount int,
+	skippedCount int,
+	elapsed time.Duration,
+	retryable int,
+	logged int,
+	writeResult string,
+	writeError string,
+	styles map[string]lipgloss.Style,
+) string {
+	title := "✅ Complete"
+	if errorCount > 0 {
+		title = "⚠️  Complete with Errors"
+	}
+	if retryPass > 0 {
+		title += fmt.Sprintf(" (retry pass %d)", retryPass)
+	}
+
+	section := styles["subtitle"].Render(title) + "\n" +
+		styles["success"].Render(fmt.Sprintf("✅ Success: %d", successCount)) + "\n"
+	if errorCount > 0 {
+		section += styles["error"].Render(fmt.Sprintf("❌ Errors: %d", errorCount)) + "\n"
+	} else {
+		section += styles["info"].Render("❌ Errors: 0") + "\n"
+	}
+	section += styles["info"].Render(fmt.Sprintf("⏭️  Skipped: %d", skippedCount)) + "\n" +
+		styles["info"].Render(fmt.Sprintf("Elapsed time: %s", elapsed.Round(time.Second)))
+
+	if errorCount > 0 {
+		section += "\n"
+		if retryable > 0 {
+			section += "\n" + styles["info"].Render(fmt.Sprintf("%d failed message(s) can be published again", retryable))
+		}
+		if errorCount > logged {
+			section += "\n" + styles["warning"].Render(fmt.Sprintf("⚠️  Only the last %d failure(s) were kept", logged))
+		}
+		if retryable < logged {
+			section += "\n" + styles["dimmed"].Render(fmt.Sprintf("%d message(s) could not be read and cannot be retried", logged-retryable))
+		}
+	}
+
+	if writeResult != "" {
+		section += "\n\n" + styles["success"].Render(writeResult)
+	}
+	if writeError != "" {
+		section += "\n\n" + styles["error"].Render(writeError)
+	}
+
+	border := styles["success"].GetForeground()
+	if errorCount > 0 {
+		border = styles["error"].GetForeground()
+	}
+	return styles["box"].Copy().BorderForeground(border).Render(section)
+}
+
+// RenderSummaryControls creates the options of the summary
+func RenderSummaryControls(hasFailures bool, styles map[string]lipgloss.Style) string {
+	controlsText := styles["subtitle"].Render("What next?") + "\n"
+	if hasFailures {
+		controlsText += styles["dimmed"].Render("r") + " Retry Failed | " +
+			styles["dimmed"].Render("w") + " Write Failed to File | " +
+			styles["dimmed"].Render("e") + " Error Log | "
+	}
+	controlsText += styles["dimmed"].Render("q") + " Quit"
+
+	return styles["controlsBox"].Render(controlsText)
+}
+
+// RenderWriteBox creates the prompt for the file to write failed messages to
+func RenderWriteBox(input string, styles map[string]lipgloss.Style) string {
+	section := styles["subtitle"].Render("Write Failed Messages") + "\n" +
+		styles["info"].Render("Type the file name and press ENTER, ESC to cancel:") + "\n" +
+		input
+
+	return styles["controlsBox"].Render(section)
+}
+
+// RenderJumpBox creates the prompt for the number of the message to jump to
+func RenderJumpBox(input string, inputError string, styles map[string]lipgloss.Style) string {
+	section := styles["subtitle"].Render("Jump to Message") + "\n" +
+		styles["info"].Render("Type the message number and press ENTER, ESC to cancel:") + "\n" +
+		input
+	if inputError != "" {
+		section += "\n" + styles["error"].Render(inputError)
+	}
+
+	return styles["controlsBox"].Render(section)
+}
+
+// RenderPreflightBox creates the pre-flight summary of the target queue.
+// args and policyDefinition are nil when unknown, for the reason given by
+// unknown. consumers is negative when unknown.
+func RenderPreflightBox(
+	exists bool,
+	depth int,
+	consumers int,
+	args []string,
+	policy string,
+	policyDefinition []string,
+	unknown string,
+	warnings []string,
+	total int,
+	styles map[string]lipgloss.Style,
+) string {
+	section := styles["subtitle"].Render("Pre-flight Checks")
+	section += "\n" + styles["success"].Render("✅ Publish permission verified")
+
+	if exists {
+		waiting := fmt.Sprintf("📥 %d message(s) in queue", depth)
+		if consumers >= 0 {
+			waiting += fmt.Sprintf(", %d consumer(s)", consumers)
+		}
+		section += "\n" + styles["info"].Render(waiting)
+		switch {
+		case args == nil:
+			section += "\n" + styles["dimmed"].Render("Arguments: unknown ("+unknown+")")
+		case len(args) == 0:
+			section += "\n" + styles["dimmed"].Render("Arguments: none")
+		default:
+			section += "\n" + styles["dimmed"].Render("Arguments: "+strings.Join(args, ", "))
+		}
+		switch {
+		case policyDefinition == nil:
+			section += "\n" + styles["dimmed"].Render("Policy: unknown ("+unknown+")")
+		case policy == "":
+			section += "\n" + styles["dimmed"].Render("Policy: none")
+		default:
+			section += "\n" + styles["dimmed"].Render(
+				fmt.Sprintf("Policy: %s (%s)", policy, strings.Join(policyDefinition, ", ")))
+		}
+	}
+
+	if total > 0 {
+		section += "\n" + styles["info"].Render(fmt.Sprintf("📤 About to publish %d message(s)", total))
+	} else {
+		section += "\n" + styles["info"].Render("📤 About to publish until stopped")
+	}
+
+	for _, warning := range warnings {
+		section += "\n" + styles["warning"].Render("⚠️  "+warning)
+	}
+
+	return styles["box"].Render(section)
+}
+
+// RenderConfirmBox creates the prompt accepting the pre-flight summary
+func RenderConfirmBox(styles map[string]lipgloss.Style) string {
+	controlsText := styles["subtitle"].Render("Start publishing?") + "\n" +
+		styles["dimmed"].Render("ENTER/y") + " Start | " +
+		styles["dimmed"].Render("q/n") + " Quit"
+
+	return styles["controlsBox"].Render(controlsText)
+}
+
+// RenderProtectedBox creates the confirmation screen of a protected target,
+// which asks for the queue name to be typed into input
+func RenderProtectedBox(
+	host string,
+	vhost string,
+	queueName string,
+	total int,
+	input string,
+	inputError string,
+	styles map[string]lipgloss.Style,
+) string {
+	count := "until stopped"
+	if total > 0 {
+		count = fmt.Sprintf("%d message(s)", total)
+	}
+
+	section := styles["subtitle"].Render("🛑 Protected Target") + "\n" +
+		styles["warning"].Render("You are about to publish to a protected broker.") + "\n\n" +
+		styles["dimmed"].Render("Host:     ") + styles["info"].Render(host) + "\n" +
+		styles["dimmed"].Render("Vhost:    ") + styles["info"].Render(vhost) + "\n" +
+		styles["dimmed"].Render("Queue:    ") + styles["info"].Render(queueName) + "\n" +
+		styles["dimmed"].Render("Messages: ") + styles["info"].Render(count) + "\n\n" +
+		styles["info"].Render("Type the queue name and press ENTER to start, ESC to quit:") + "\n" +
+		input
+	if inputError != "" {
+		section += "\n" + styles["error"].Render(inputError)
+	}
+
+	return styles["box"].Copy().BorderForeground(styles["error"].GetForeground()).Render(section)
+}
+
 // RenderEmptyState creates a message for when there are no messages to process
 func RenderEmptyState(styles map[string]lipgloss.Style) string {
 	return styles["box"].Render("No messages to process.") + "\n\n" +
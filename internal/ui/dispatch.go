@@ -0,0 +1,242 @@
+package ui
+
+import (
+	"context"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/marianozunino/go-publish/internal/ordering"
+)
+
+// laneBuffer is how many messages per lane may be read ahead of publishing
+// when ordering by key, so other lanes keep busy while one key is hot
+const laneBuffer = 64
+
+// dispatch hands messages to idle lanes and returns the commands publishing
+// them. It is the single place deciding what gets published next, so pause,
+// delay, rate and original timing apply across all lanes at once. While
+// paused, only the messages stepped through are handed out.
+func (m Model) dispatch() (Model, tea.Cmd) {
+	if m.UI.Confirming || m.Publisher.Reconnecting || (m.UI.IsPaused && m.Publisher.Steps == 0) {
+		return m, nil
+	}
+
+	var cmds []tea.Cmd
+	if m.Publisher.OrderKey != nil {
+		m, cmds = m.dispatchOrdered()
+	} else {
+		m, cmds = m.dispatchUnordered()
+	}
+
+	// Original timing: come back when the next message is due
+	if m.Timing.Enabled && !m.UI.IsPaused && !m.Timing.Waiting && m.hasUndue() {
+		m.Timing.Waiting = true
+		cmds = append(cmds, scheduleCmd(m))
+	}
+
+	return m, tea.Batch(cmds...)
+}
+
+// dispatchUnordered gives the next message to whichever lane is idle
+func (m Model) dispatchUnordered() (Model, []tea.Cmd) {
+	var cmds []tea.Cmd
+	for lane := range m.Publisher.Lanes {
+		if m.Publisher.Lanes[lane].Busy {
+			continue
+		}
+		if m.UI.IsPaused && m.Publisher.Steps == 0 {
+			break
+		}
+		var d pending
+		var ok bool
+		m, d, ok = m.nextDue()
+		if !ok {
+			break
+		}
+		if !d.ok() {
+			m = m.recordResult(-1, d.delivery, false, d.err)
+			continue
+		}
+		d.lane = lane
+		var cmd tea.Cmd
+		m, cmd = m.start(d.delivery)
+		cmds = append(cmds, cmd)
+	}
+	return m, cmds
+}
+
+// dispatchOrdered queues messages on the lane their key hashes to and starts
+// the head of every idle lane's queue
+func (m Model) dispatchOrdered() (Model, []tea.Cmd) {
+	lanes := len(m.Publisher.Lanes)
+	// While paused, read no further than the message to step through
+	for m.queued() < lanes*laneBuffer && (!m.UI.IsPaused || m.queued() == 0) {
+		var d pending
+		var ok bool
+		m, d, ok = m.nextDue()
+		if !ok {
+			break
+		}
+		if !d.ok() {
+			m = m.recordResult(-1, d.delivery, false, d.err)
+			continue
+		}
+		d.lane = ordering.Lane(m.Publisher.OrderKey(d.msg), lanes)
+		m.Publisher.Lanes[d.lane].Queue = append(m.Publisher.Lanes[d.lane].Queue, d.delivery)
+	}
+
+	// Oldest first, so stepping through keeps to the order of the messages
+	var cmds []tea.Cmd
+	for !m.UI.IsPaused || m.Publisher.Steps > 0 {
+		lane := m.oldestQueuedLane(true)
+		if lane < 0 {
+			break
+		}
+		state := &m.Publisher.Lanes[lane]
+		d := state.Queue[0]
+		state.Queue = state.Queue[1:]
+		var cmd tea.Cmd
+		m, cmd = m.start(d)
+		cmds = append(cmds, cmd)
+	}
+	return m, cmds
+}
+
+// pending is the outcome of reading the next message: a delivery, or the
+// error rendering it
+type pending struct {
+	delivery
+	err error
+}
+
+func (p pending) ok() bool { return p.err == nil }
+
+// nextDue reads the message at the cursor and advances it, unless there are
+// no more messages or the message is not due yet under original timing
+func (m Model) nextDue() (Model, pending, bool) {
+	// Messages interrupted by a failover go first
+	if len(m.Publisher.Retry) > 0 {
+		d := m.Publisher.Retry[0]
+		m.Publisher.Retry = m.Publisher.Retry[1:]
+		return m, pending{delivery: d}, true
+	}
+
+	// Messages marked to be excluded are passed over
+	for m.Publisher.Excluded[m.Publisher.CurrentIndex] &&
+		(m.Publisher.Unbounded || m.Publisher.CurrentIndex < m.Publisher.TotalMessages) {
+		m.Publisher.CurrentIndex++
+		m.Stats.SkippedCount++
+	}
+
+	idx := m.Publisher.CurrentIndex
+	if !m.Publisher.Unbounded && idx >= m.Publisher.TotalMessages {
+		return m, pending{}, false
+	}
+	if m.Timing.Enabled {
+		// A message stepped through while paused goes at once, and the
+		// schedule carries on from it
+		if m.UI.IsPaused {
+			m.Timing.AnchorOffset = m.Timing.Offsets[idx]
+			m.Timing.Anchor = time.Now()
+		} else if time.Now().Before(m.scheduledAt(idx)) {
+			return m, pending{}, false
+		}
+	}
+
+	m.Publisher.CurrentIndex++
+	msg, err := m.messageAt(idx)
+	return m, pending{delivery: delivery{index: idx, msg: msg}, err: err}, true
+}
+
+// requeue frees the lane of an interrupted delivery and puts the message back
+// to be published again, ahead of its lane's queue when ordering by key
+func (m Model) requeue(d delivery) Model {
+	m = m.release(d.lane)
+	d.notBefore = time.Time{}
+
+	if m.Publisher.OrderKey != nil {
+		lane := &m.Publisher.Lanes[d.lane]
+		lane.Queue = append([]delivery{d}, lane.Queue...)
+	} else {
+		m.Publisher.Retry = append(m.Publisher.Retry, d)
+	}
+	return m
+}
+
+// hasUndue reports whether messages remain that are only held back by timing
+func (m Model) hasUndue() bool {
+	return m.Publisher.Unbounded || m.Publisher.CurrentIndex < m.Publisher.TotalMessages
+}
+
+// start marks the lane busy and returns the command publishing d on it
+func (m Model) start(d delivery) (Model, tea.Cmd) {
+	// A fixed delay is enforced globally by handing out send slots
+	if !m.Timing.Enabled && m.Publisher.Limiter == nil && m.Publisher.Delay > 0 {
+		slot := time.Now()
+		if m.Publisher.NextSlot.After(slot) {
+			slot = m.Publisher.NextSlot
+		}
+		d.notBefore = slot
+		m.Publisher.NextSlot = slot.Add(m.Publisher.Delay)
+	}
+
+	if m.UI.IsPaused {
+		m.Publisher.Steps--
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.Publisher.Lanes[d.lane].Busy = true
+	m.Publisher.Lanes[d.lane].cancel = cancel
+	m.Publisher.InFlight++
+	return m, publishMessageCmd(ctx, m, d)
+}
+
+// release marks the lane idle again once its message is done
+func (m Model) release(lane int) Model {
+	if cancel := m.Publisher.Lanes[lane].cancel; cancel != nil {
+		cancel()
+	}
+	m.Publisher.Lanes[lane].Busy = false
+	m.Publisher.Lanes[lane].cancel = nil
+	m.Publisher.InFlight--
+	return m
+}
+
+// cancelWaits cuts short the waits for the rate limiter, so that a new rate
+// or a pause takes effect at once. The messages are handed out again.
+func (m Model) cancelWaits() {
+	for _, lane := range m.Publisher.Lanes {
+		if lane.cancel != nil {
+			lane.cancel()
+		}
+	}
+}
+
+// recordResult updates the counters once the message of d is done, and keeps
+// it in the error log when it failed. lane is -1 when the message never
+// reached a lane.
+func (m Model) recordResult(lane int, d delivery, success bool, err error) Model {
+	if lane >= 0 {
+		m = m.release(lane)
+		m.Publisher.Lanes[lane].Done++
+	}
+	m.Publisher.Completed++
+	if success {
+		m.Stats.SuccessCount++
+	} else {
+		m.Stats.ErrorCount++
+		if err != nil {
+			m.Publisher.LastError = err.Error()
+			m = m.logFailure(d, err, lane >= 0)
+		}
+	}
+	return m
+}
+
+// queued returns how many messages wait in lane queues or for a retry
+func (m Model) queued() int {
+	n := len(m.Publisher.Retry)
+	for _, lane := range m.Publisher.Lanes {
+		n += len(lane.Queue)
+	}
+	return n
+}
@@ -0,0 +1,183 @@
+package ui
+
+import (
+	"sort"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/marianozunino/go-publish/internal/ordering"
+	"github.com/marianozunino/go-publish/internal/ui/components"
+)
+
+const (
+	// maxErrorLog is how many failures the error log keeps, dropping the oldest
+	maxErrorLog = 1000
+	// errorLogLines is how many lines of the error log are shown at once
+	errorLogLines = 20
+)
+
+// failure is a message that failed to publish, kept in the error log
+type failure struct {
+	at        time.Time
+	delivery  delivery
+	err       string
+	retryable bool // false when the message could not be read, so there is nothing to publish
+}
+
+// logFailure adds the failed message of d to the error log
+func (m Model) logFailure(d delivery, err error, retryable bool) Model {
+	log := m.Publisher.Errors
+	if len(log) >= maxErrorLog {
+		log = log[len(log)-maxErrorLog+1:]
+	}
+	m.Publisher.Errors = append(log, failure{
+		at:        time.Now(),
+		delivery:  d,
+		err:       err.Error(),
+		retryable: retryable,
+	})
+	if retryable {
+		m.Publisher.Failed = append(m.Publisher.Failed, d)
+	}
+	return m
+}
+
+// dropFailed takes the delivery of a failure being retried off the failed
+// messages, as it no longer counts as an error
+func (m Model) dropFailed(d delivery) Model {
+	for i, f := range m.Publisher.Failed {
+		if f.index == d.index {
+			m.Publisher.Failed = append(m.Publisher.Failed[:i], m.Publisher.Failed[i+1:]...)
+			break
+		}
+	}
+	return m
+}
+
+// errorOrder returns the positions in the error log in the order they are
+// listed: grouped by error text, the most frequent first, and the latest
+// failure first within a group
+func (m Model) errorOrder() []int {
+	log := m.Publisher.Errors
+	counts := make(map[string]int)
+	latest := make(map[string]int)
+	for i, f := range log {
+		counts[f.err]++
+		latest[f.err] = i
+	}
+
+	order := make([]int, len(log))
+	for i := range order {
+		order[i] = len(log) - 1 - i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ea, eb := log[order[a]].err, log[order[b]].err
+		if ea == eb {
+			return false
+		}
+		if counts[ea] != counts[eb] {
+			return counts[ea] > counts[eb]
+		}
+		return latest[ea] > latest[eb]
+	})
+	return order
+}
+
+// toggleErrorLog shows or hides the error log
+func (m Model) toggleErrorLog() (tea.Model, tea.Cmd) {
+	m.UI.ErrorLog = !m.UI.ErrorLog
+	m.UI.ErrorCursor = 0
+	return m, nil
+}
+
+// moveErrorCursor selects another failure in the error log
+func (m Model) moveErrorCursor(delta int) (tea.Model, tea.Cmd) {
+	cursor := m.UI.ErrorCursor + delta
+	if cursor >= len(m.Publisher.Errors) {
+		cursor = len(m.Publisher.Errors) - 1
+	}
+	if cursor < 0 {
+		cursor = 0
+	}
+	m.UI.ErrorCursor = cursor
+	return m, nil
+}
+
+// retrySelected publishes the selected failure again
+func (m Model) retrySelected() (tea.Model, tea.Cmd) {
+	order := m.errorOrder()
+	if m.UI.ErrorCursor >= len(order) {
+		return m, nil
+	}
+	m = m.retry(map[int]bool{order[m.UI.ErrorCursor]: true})
+	if m.UI.ErrorCursor >= len(m.Publisher.Errors) && m.UI.ErrorCursor > 0 {
+		m.UI.ErrorCursor--
+	}
+	return m.dispatch()
+}
+
+// retryAll publishes every failure in the error log again
+func (m Model) retryAll() (tea.Model, tea.Cmd) {
+	all := make(map[int]bool, len(m.Publisher.Errors))
+	for i := range m.Publisher.Errors {
+		all[i] = true
+	}
+	m = m.retry(all)
+	m.UI.ErrorCursor = 0
+	return m.dispatch()
+}
+
+// retry takes the failures at the given log positions out of the error log
+// and queues their messages to be published again, on the lane of their key
+// when ordering. They no longer count as errors; failing again logs them anew.
+func (m Model) retry(positions map[int]bool) Model {
+	var kept []failure
+	for i, f := range m.Publisher.Errors {
+		if !positions[i] || !f.retryable {
+			kept = append(kept, f)
+			continue
+		}
+
+		d := f.delivery
+		d.notBefore = time.Time{}
+		m = m.dropFailed(d)
+		m.Stats.ErrorCount--
+		if m.Publisher.OrderKey != nil {
+			d.lane = ordering.Lane(m.Publisher.OrderKey(d.msg), len(m.Publisher.Lanes))
+			lane := &m.Publisher.Lanes[d.lane]
+			lane.Queue = append(lane.Queue, d)
+		} else {
+			m.Publisher.Retry = append(m.Publisher.Retry, d)
+		}
+	}
+	m.Publisher.Errors = kept
+	m.Stats.FinishTime = time.Time{}
+	return m
+}
+
+// errorLogView renders the error log, grouped by error text
+func (m Model) errorLogView(width int) string {
+	var groups []components.ErrorGroup
+	for _, i := range m.errorOrder() {
+		f := m.Publisher.Errors[i]
+		if len(groups) == 0 || groups[len(groups)-1].Error != f.err {
+			groups = append(groups, components.ErrorGroup{Error: f.err})
+		}
+		group := &groups[len(groups)-1]
+		group.Failures = append(group.Failures, components.ErrorEntry{
+			At:         f.at,
+			Position:   f.delivery.index + 1,
+			RoutingKey: f.delivery.msg.RoutingKey,
+			Retryable:  f.retryable,
+		})
+	}
+
+	return components.RenderErrorLog(
+		groups,
+		m.Stats.ErrorCount,
+		m.UI.ErrorCursor,
+		errorLogLines,
+		width,
+		m.getStylesMap(),
+	)
+}
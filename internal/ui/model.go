@@ -1,16 +1,51 @@
 package ui
 
 import (
+	"io"
 	"time"
 
 	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/streadway/amqp"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/marianozunino/go-publish/internal/generator"
 	"github.com/marianozunino/go-publish/internal/models"
+	"github.com/marianozunino/go-publish/internal/ordering"
+	"github.com/marianozunino/go-publish/internal/publisher"
+	"golang.org/x/time/rate"
 )
 
+// Options holds everything needed to start a publishing session
+type Options struct {
+	Messages    []models.RawMessage
+	Generator   *generator.Generator // when set, messages are rendered instead of read from Messages
+	Count       int                  // number of messages to generate, 0 means indefinitely
+	Connector   publisher.Connector  // used to fail over when the connection is lost
+	Conn        publisher.Publisher  // messages are fanned out across its lanes
+	OrderKey    ordering.KeyFunc
+	QueueName   string
+	DelayMs     int
+	InsecureTLS bool
+
+	// Preflight, when set, is shown for confirmation before the first message is sent
+	Preflight *publisher.Preflight
+	// Protected targets require typing the queue name before the first message is sent
+	Protected bool
+
+	// Rate enforces a target number of messages per second instead of DelayMs when > 0
+	Rate  float64
+	Burst int
+
+	// TimingOffsets enables original timing replay when set, one offset per message
+	TimingOffsets []time.Duration
+	Speed         float64
+
+	// Output is where the UI is drawn, stdout when nil
+	Output io.Writer
+}
+
 // NewModel initializes the application model
-func NewModel(messages []models.RawMessage, conn *amqp.Connection, ch *amqp.Channel, queueName string, delayMs int, insecureTLS bool) Model {
+func NewModel(opts Options) Model {
 	// Configure progress bar with custom style
 	theme := DefaultTheme()
 	p := progress.New(
@@ -23,25 +58,56 @@ func NewModel(messages []models.RawMessage, conn *amqp.Connection, ch *amqp.Chan
 	p.FullColor = string(theme.Primary)
 	p.EmptyColor = string(theme.Dimmed)
 
+	total := len(opts.Messages)
+	if opts.Generator != nil {
+		total = opts.Count
+	}
+
+	var limiter *rate.Limiter
+	if opts.Rate > 0 {
+		burst := opts.Burst
+		if burst < 1 {
+			burst = 1
+		}
+		limiter = rate.NewLimiter(rate.Limit(opts.Rate), burst)
+	}
+
 	return Model{
 		Publisher: PublisherState{
-			Messages:      messages,
+			Messages:      opts.Messages,
+			Generator:     opts.Generator,
+			Unbounded:     opts.Generator != nil && opts.Count <= 0,
 			CurrentIndex:  0,
-			TotalMessages: len(messages),
-			Delay:         time.Duration(delayMs) * time.Millisecond,
-			Connection:    conn,
-			Channel:       ch,
-			QueueName:     queueName,
-			InsecureTLS:   insecureTLS,
+			TotalMessages: total,
+			Delay:         time.Duration(opts.DelayMs) * time.Millisecond,
+			Limiter:       limiter,
+			Connector:     opts.Connector,
+			Conn:          opts.Conn,
+			Lanes:         make([]LaneState, opts.Conn.Lanes()),
+			OrderKey:      opts.OrderKey,
+			QueueName:     opts.QueueName,
+			InsecureTLS:   opts.InsecureTLS,
+			Preflight:     opts.Preflight,
+			Protected:     opts.Protected,
 			LastError:     "",
 		},
+		Timing: TimingState{
+			Enabled: opts.TimingOffsets != nil,
+			Offsets: opts.TimingOffsets,
+			Speed:   opts.Speed,
+			Anchor:  time.Now(),
+		},
 		UI: UIState{
-			Progress: p,
-			IsPaused: false,
-			Width:    80,
-			Height:   24,
-			Theme:    theme,
-			Styles:   DefaultStyles(theme),
+			Progress:     p,
+			IsPaused:     false,
+			Confirming:   opts.Preflight != nil || opts.Protected,
+			ConfirmInput: newConfirmInput(opts.QueueName),
+			JumpInput:    newJumpInput(),
+			WriteInput:   newWriteInput(),
+			Width:        80,
+			Height:       24,
+			Theme:        theme,
+			Styles:       DefaultStyles(theme),
 		},
 		Stats: Statistics{
 			SuccessCount:    0,
@@ -53,31 +119,89 @@ func NewModel(messages []models.RawMessage, conn *amqp.Connection, ch *amqp.Chan
 	}
 }
 
+// newConfirmInput creates the field the queue name is typed into to confirm
+// publishing to a protected target
+func newConfirmInput(queueName string) textinput.Model {
+	input := textinput.New()
+	input.Placeholder = queueName
+	input.Prompt = "> "
+	input.Focus()
+	return input
+}
+
 // Init initializes the Bubble Tea program
 func (m Model) Init() tea.Cmd {
-	return tea.Batch(
+	cmds := []tea.Cmd{
 		tickCmd(),
-		publishMessageCmd(m),
-	)
+		watchConnectionCmd(m.Publisher.Conn, m.Publisher.Generation),
+	}
+	// Init cannot hand back an updated model, so the first dispatch happens
+	// in response to startMsg, or once the pre-flight summary is confirmed
+	if !m.UI.Confirming {
+		cmds = append(cmds, func() tea.Msg { return startMsg{} })
+	}
+	return tea.Batch(cmds...)
 }
 
 // IsComplete returns true if all messages have been processed
 func (m Model) IsComplete() bool {
-	return m.Publisher.CurrentIndex >= m.Publisher.TotalMessages
+	if m.Publisher.Unbounded {
+		return false
+	}
+	return m.Publisher.CurrentIndex >= m.Publisher.TotalMessages &&
+		m.Publisher.InFlight == 0 && m.queued() == 0
 }
 
 // IsEmpty returns true if there are no messages to process
 func (m Model) IsEmpty() bool {
-	return m.Publisher.TotalMessages == 0
+	return m.Publisher.TotalMessages == 0 && !m.Publisher.Unbounded
 }
 
-// StartTUI initializes and runs the terminal UI
-func StartTUI(messages []models.RawMessage, conn *amqp.Connection, ch *amqp.Channel, queueName string, delayMs int, insecureTLS bool) error {
-	p := tea.NewProgram(
-		NewModel(messages, conn, ch, queueName, delayMs, insecureTLS),
-		tea.WithAltScreen(),
-	)
+// messageAt returns the message at the given index, rendering it when generating
+func (m Model) messageAt(idx int) (models.RawMessage, error) {
+	if m.Publisher.Generator != nil {
+		return m.Publisher.Generator.Render(idx)
+	}
+	return m.Publisher.Messages[idx], nil
+}
+
+// scheduledAt returns when the message at idx is due under original timing
+func (m Model) scheduledAt(idx int) time.Time {
+	offset := m.Timing.Offsets[idx] - m.Timing.AnchorOffset
+	return m.Timing.Anchor.Add(time.Duration(float64(offset) / m.Timing.Speed))
+}
 
-	_, err := p.Run()
+// playbackPosition returns how far into the original recording the replay is at t
+func (m Model) playbackPosition(t time.Time) time.Duration {
+	return m.Timing.AnchorOffset + time.Duration(float64(t.Sub(m.Timing.Anchor))*m.Timing.Speed)
+}
+
+// reanchor moves the schedule anchor to now without skipping ahead or back,
+// so the speed can be changed or the replay paused mid-gap
+func (m Model) reanchor() Model {
+	now := time.Now()
+	m.Timing.AnchorOffset = m.playbackPosition(now)
+	m.Timing.Anchor = now
+	return m
+}
+
+// StartTUI initializes and runs the terminal UI. It takes ownership of the
+// session, which may be replaced on failover, and closes it on exit.
+func StartTUI(opts Options) error {
+	programOpts := []tea.ProgramOption{tea.WithAltScreen()}
+	if opts.Output != nil {
+		programOpts = append(programOpts, tea.WithOutput(opts.Output))
+		// Pick colors for the terminal actually drawn to
+		lipgloss.SetDefaultRenderer(lipgloss.NewRenderer(opts.Output))
+	}
+
+	p := tea.NewProgram(NewModel(opts), programOpts...)
+
+	final, err := p.Run()
+	if m, ok := final.(Model); ok {
+		m.Publisher.Conn.Close()
+	} else {
+		opts.Conn.Close()
+	}
 	return err
 }
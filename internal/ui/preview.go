@@ -0,0 +1,165 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/marianozunino/go-publish/internal/models"
+	"github.com/marianozunino/go-publish/internal/ui/components"
+)
+
+const (
+	// previewMessages is how many messages the preview pane shows at once
+	previewMessages = 3
+	// previewBodyLines caps the body lines shown per message
+	previewBodyLines = 8
+)
+
+// togglePreview shows or hides the preview pane, which starts at the next
+// message to be published
+func (m Model) togglePreview() (tea.Model, tea.Cmd) {
+	m.UI.Preview = !m.UI.Preview
+	m.UI.PreviewOffset = 0
+	return m, nil
+}
+
+// scrollPreview moves the preview pane by delta messages. It only scrolls
+// while paused, as the cursor moves on under it otherwise.
+func (m Model) scrollPreview(delta int) (tea.Model, tea.Cmd) {
+	if !m.UI.Preview || !m.UI.IsPaused {
+		return m, nil
+	}
+
+	next := m.nextPending()
+	offset := m.UI.PreviewOffset + delta
+	// Back as far as the first message, forward until the last one shows
+	if !m.Publisher.Unbounded {
+		last := m.Publisher.TotalMessages - previewMessages
+		if last < 0 {
+			last = 0
+		}
+		if offset > last-next {
+			offset = last - next
+		}
+	}
+	if first := -next; offset < first {
+		offset = first
+	}
+	m.UI.PreviewOffset = offset
+	return m, nil
+}
+
+// previewView renders the preview pane when it is shown
+func (m Model) previewView(width int) string {
+	if !m.UI.Preview {
+		return ""
+	}
+
+	var previews []components.MessagePreview
+	next := m.nextPending()
+	readAhead := m.readAhead()
+	start := next + m.UI.PreviewOffset
+	for idx := start; idx < start+previewMessages; idx++ {
+		if idx < 0 || (!m.Publisher.Unbounded && idx >= m.Publisher.TotalMessages) {
+			continue
+		}
+		d, read := readAhead[idx]
+		previews = append(previews, m.preview(idx, idx == next, d, read, width))
+	}
+
+	return components.RenderPreviewSection(
+		previews,
+		m.Publisher.Generator != nil,
+		m.UI.IsPaused,
+		m.getStylesMap(),
+	)
+}
+
+// preview summarizes the message at idx. A message read ahead, d when read,
+// is still to be published and shown as it will be; others past the cursor
+// are rendered when generating.
+func (m Model) preview(idx int, next bool, d delivery, read bool, width int) components.MessagePreview {
+	p := components.MessagePreview{
+		Position: idx + 1,
+		Total:    m.Publisher.TotalMessages,
+		Next:     next,
+		Sent:     idx < m.Publisher.CurrentIndex && !read,
+		Excluded: m.Publisher.Excluded[idx],
+	}
+
+	msg := d.msg
+	if !read {
+		var err error
+		if msg, err = m.messageAt(idx); err != nil {
+			p.Error = err.Error()
+			return p
+		}
+	}
+
+	p.Exchange = msg.Exchange
+	p.RoutingKey = msg.RoutingKey
+	p.Properties = previewProperties(msg.Properties)
+	p.Body, p.MoreLines = previewBody(msg.Payload, width)
+	return p
+}
+
+// previewProperties lists the properties of a message that are published,
+// headers sorted by name
+func previewProperties(props models.MessageProperties) []string {
+	var lines []string
+	if props.ContentType != "" {
+		lines = append(lines, "content_type="+props.ContentType)
+	}
+	if props.DeliveryMode != 0 {
+		lines = append(lines, fmt.Sprintf("delivery_mode=%d", props.DeliveryMode))
+	}
+	if props.Priority != 0 {
+		lines = append(lines, fmt.Sprintf("priority=%d", props.Priority))
+	}
+	if props.MessageID != "" {
+		lines = append(lines, "message_id="+props.MessageID)
+	}
+	if props.Timestamp != 0 {
+		lines = append(lines, "timestamp="+time.Unix(props.Timestamp, 0).UTC().Format(time.RFC3339))
+	}
+
+	names := make([]string, 0, len(props.Headers))
+	for name := range props.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value, _ := json.Marshal(props.Headers[name])
+		lines = append(lines, fmt.Sprintf("header %s=%s", name, value))
+	}
+	return lines
+}
+
+// previewBody pretty-prints a JSON payload, leaving anything else as it is,
+// and truncates it to previewBodyLines lines of at most width characters.
+// It also returns how many lines were left out.
+func previewBody(payload string, width int) ([]string, int) {
+	body := payload
+	var pretty bytes.Buffer
+	if json.Indent(&pretty, []byte(payload), "", "  ") == nil {
+		body = pretty.String()
+	}
+
+	lines := strings.Split(strings.TrimRight(body, "\n"), "\n")
+	more := 0
+	if len(lines) > previewBodyLines {
+		more = len(lines) - previewBodyLines
+		lines = lines[:previewBodyLines]
+	}
+	for i, line := range lines {
+		if runes := []rune(line); len(runes) > width {
+			lines[i] = string(runes[:width-1]) + "…"
+		}
+	}
+	return lines, more
+}
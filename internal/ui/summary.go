@@ -0,0 +1,157 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/marianozunino/go-publish/internal/models"
+	"github.com/marianozunino/go-publish/internal/ui/components"
+)
+
+// newWriteInput creates the field the file to write failed messages to is typed into
+func newWriteInput() textinput.Model {
+	input := textinput.New()
+	input.Placeholder = "failed.ndjson"
+	input.Prompt = "> "
+	return input
+}
+
+// handleSummaryKey acts on the options of the summary shown once the run is complete
+func (m Model) handleSummaryKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	case "r":
+		return m.retryPass()
+	case "w":
+		return m.startWrite()
+	case "e":
+		return m.toggleErrorLog()
+	}
+	return m, nil
+}
+
+// failedMessages returns the messages of all retryable failures, not only
+// those still in the error log, in the order they were read
+func (m Model) failedMessages() []models.RawMessage {
+	failed := append([]delivery(nil), m.Publisher.Failed...)
+	sort.SliceStable(failed, func(i, j int) bool {
+		return failed[i].index < failed[j].index
+	})
+
+	msgs := make([]models.RawMessage, len(failed))
+	for i, d := range failed {
+		msgs[i] = d.msg
+	}
+	return msgs
+}
+
+// retryPass publishes the failed messages again as a run of their own, with
+// fresh counters. They are published as they were the first time, so
+// generated messages are not rendered again and original timing no longer
+// applies.
+func (m Model) retryPass() (tea.Model, tea.Cmd) {
+	msgs := m.failedMessages()
+	if len(msgs) == 0 {
+		return m, nil
+	}
+
+	m.Publisher.Messages = msgs
+	m.Publisher.Generator = nil
+	m.Publisher.Unbounded = false
+	m.Publisher.TotalMessages = len(msgs)
+	m.Publisher.CurrentIndex = 0
+	m.Publisher.Completed = 0
+	m.Publisher.Lanes = make([]LaneState, len(m.Publisher.Lanes))
+	m.Publisher.Retry = nil
+	m.Publisher.Steps = 0
+	m.Publisher.Excluded = nil
+	m.Publisher.Errors = nil
+	m.Publisher.Failed = nil
+	m.Publisher.LastError = ""
+	m.Publisher.RetryPass++
+	m.Timing.Enabled = false
+
+	m.Stats = Statistics{StartTime: time.Now()}
+	m.UI.IsPaused = false
+	m.UI.Preview = false
+	m.UI.PreviewOffset = 0
+	m.UI.ErrorLog = false
+	m.UI.ErrorCursor = 0
+	m.UI.WriteResult = ""
+	return m.dispatch()
+}
+
+// startWrite opens the prompt for the file to write the failed messages to
+func (m Model) startWrite() (tea.Model, tea.Cmd) {
+	if len(m.failedMessages()) == 0 {
+		return m, nil
+	}
+	m.UI.Writing = true
+	m.UI.WriteError = ""
+	m.UI.WriteInput.SetValue("")
+	return m, m.UI.WriteInput.Focus()
+}
+
+// handleWriteKey edits the typed file name, writing the failed messages to
+// it on enter
+func (m Model) handleWriteKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		path := strings.TrimSpace(m.UI.WriteInput.Value())
+		if path == "" {
+			path = m.UI.WriteInput.Placeholder
+		}
+		m.UI.Writing = false
+		m.UI.WriteInput.Blur()
+		return m, writeFailedCmd(path, m.failedMessages())
+	case "esc":
+		m.UI.Writing = false
+		m.UI.WriteInput.Blur()
+		return m, nil
+	case "ctrl+c":
+		return m, tea.Quit
+	}
+
+	var cmd tea.Cmd
+	m.UI.WriteInput, cmd = m.UI.WriteInput.Update(msg)
+	return m, cmd
+}
+
+// handleWrittenMsg reports how writing the failed messages went
+func (m Model) handleWrittenMsg(msg writtenMsg) (tea.Model, tea.Cmd) {
+	if msg.err != nil {
+		m.UI.WriteError = msg.err.Error()
+		m.UI.WriteResult = ""
+		return m, nil
+	}
+	m.UI.WriteError = ""
+	m.UI.WriteResult = fmt.Sprintf("Wrote %d message(s) to %s; replay them with -i %s", msg.count, msg.path, msg.path)
+	return m, nil
+}
+
+// summaryView renders the summary shown once the run is complete
+func (m Model) summaryView() string {
+	retryable := len(m.failedMessages())
+	s := components.RenderSummary(
+		m.Publisher.RetryPass,
+		m.Stats.SuccessCount,
+		m.Stats.ErrorCount,
+		m.Stats.SkippedCount,
+		m.calculateElapsedTime(),
+		retryable,
+		len(m.Publisher.Errors),
+		m.UI.WriteResult,
+		m.UI.WriteError,
+		m.getStylesMap(),
+	)
+
+	if m.UI.Writing {
+		return s + components.RenderWriteBox(m.UI.WriteInput.View(), m.getStylesMap())
+	}
+	return s + components.RenderSummaryControls(retryable > 0, m.getStylesMap())
+}
@@ -1,48 +1,127 @@
 package ui
 
 import (
+	"context"
 	"time"
 
 	"github.com/charmbracelet/bubbles/progress"
-	"github.com/streadway/amqp"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/marianozunino/go-publish/internal/generator"
 	"github.com/marianozunino/go-publish/internal/models"
+	"github.com/marianozunino/go-publish/internal/ordering"
+	"github.com/marianozunino/go-publish/internal/publisher"
+	"golang.org/x/time/rate"
 )
 
 // PublisherState holds the data relevant to the message publishing logic
 type PublisherState struct {
 	Messages      []models.RawMessage
-	CurrentIndex  int
+	Generator     *generator.Generator // renders messages on demand instead of Messages
+	Unbounded     bool                 // keep generating until the user quits
+	CurrentIndex  int                  // next message to hand out to a lane
+	Completed     int                  // messages whose publish finished, successfully or not
 	TotalMessages int
 	Delay         time.Duration
-	Connection    *amqp.Connection
-	Channel       *amqp.Channel
+	NextSlot      time.Time     // earliest send time of the next message under Delay
+	Limiter       *rate.Limiter // enforces a target rate instead of Delay when set
+	Connector     publisher.Connector
+	Conn          publisher.Publisher // current connection, offering one lane per LaneState
+	Generation    int                 // bumped on every failover, to ignore stale notifications
+	Reconnecting  bool                // publishing is held while failing over
+	Failovers     int                 // successful reconnections so far
+	Lanes         []LaneState         // one per channel
+	Retry         []delivery          // messages to publish again before the cursor moves on
+	Steps         int                 // messages still to publish while paused, one per step
+	Excluded      map[int]bool        // indexes of messages to skip once the cursor gets to them
+	OrderKey      ordering.KeyFunc    // pins messages sharing a key to one lane when set
+	InFlight      int
 	QueueName     string
 	InsecureTLS   bool
+	Preflight     *publisher.Preflight // target queue checks shown before starting
+	Protected     bool                 // the queue name must be typed to start
 	LastError     string
+	Errors        []failure // the latest failures, oldest first
+	// Failed holds the deliveries of every retryable failure, which the
+	// error log only keeps the latest of, for the retry pass and write-out.
+	// The messages are kept, as generated ones render differently each time.
+	Failed    []delivery
+	RetryPass int // runs of failed messages published again after completing
+}
+
+// LaneState tracks one publishing lane, which owns a channel and publishes
+// a single message at a time
+type LaneState struct {
+	Busy  bool
+	Queue []delivery // messages pinned to this lane by their ordering key
+	Done  int        // messages this lane finished publishing, successfully or not
+
+	cancel context.CancelFunc // cuts short the lane's wait for the rate limiter
+}
+
+// delivery is a message handed to a lane for publishing
+type delivery struct {
+	lane      int
+	index     int
+	msg       models.RawMessage
+	notBefore time.Time // set when pacing with a fixed delay
+}
+
+// TimingState holds the data for replaying messages with their original spacing
+type TimingState struct {
+	Enabled      bool
+	Offsets      []time.Duration // offset of each message from the first one
+	Speed        float64         // playback multiplier, 2 means twice as fast
+	Anchor       time.Time       // wall clock time AnchorOffset is scheduled at
+	AnchorOffset time.Duration
+	Waiting      bool // a schedule check is already pending
 }
 
 // UIState holds the data relevant to the user interface
 type UIState struct {
-	Progress progress.Model
-	IsPaused bool
-	Width    int
-	Height   int
-	Theme    Theme
-	Styles   Styles
+	Progress      progress.Model
+	IsPaused      bool
+	Confirming    bool            // waiting for the user to accept the pre-flight summary
+	ConfirmInput  textinput.Model // queue name typed to confirm a protected target
+	ConfirmError  string
+	Preview       bool // show the messages around the cursor
+	PreviewOffset int  // messages the preview is scrolled from the cursor, while paused
+	Jumping       bool // waiting for the number of the message to jump to
+	JumpInput     textinput.Model
+	JumpError     string
+	ErrorLog      bool // show the error log instead of the lanes and preview
+	ErrorCursor   int  // selected failure, in the order the error log lists them
+	Writing       bool // waiting for the file to write failed messages to
+	WriteInput    textinput.Model
+	WriteError    string
+	WriteResult   string
+	Width         int
+	Height        int
+	Theme         Theme
+	Styles        Styles
 }
 
 // Statistics holds the data relevant to tracking performance and timing
 type Statistics struct {
 	SuccessCount    int
 	ErrorCount      int
+	SkippedCount    int // messages passed over without publishing them
 	StartTime       time.Time
+	FinishTime      time.Time     // when the run completed, zero while running
 	PauseStartTime  time.Time     // Track when pause starts
 	TotalPausedTime time.Duration // Track total paused time
+	RateSamples     []rateSample  // Recent progress snapshots for the current rate
+}
+
+// rateSample records how many messages had been processed at a point in time
+type rateSample struct {
+	at    time.Time
+	count int
 }
 
 // Model represents the overall application state
 type Model struct {
 	Publisher PublisherState
+	Timing    TimingState
 	UI        UIState
 	Stats     Statistics
 }
@@ -50,8 +129,26 @@ type Model struct {
 // Define UI messages
 type (
 	tickMsg          time.Time
+	startMsg         struct{}
 	publishResultMsg struct {
-		success bool
-		err     error
+		delivery   delivery
+		generation int  // of the session the message was published on
+		cancelled  bool // the wait for the rate limiter was cut short, nothing was sent
+		success    bool
+		err        error
+	}
+	scheduleMsg       struct{}
+	connectionLostMsg struct {
+		generation int
+		err        error
+	}
+	reconnectedMsg struct {
+		conn publisher.Publisher
+		err  error
+	}
+	writtenMsg struct {
+		path  string
+		count int
+		err   error
 	}
 )
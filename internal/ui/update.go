@@ -1,9 +1,12 @@
 package ui
 
 import (
+	"fmt"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/marianozunino/go-publish/internal/publisher"
+	"golang.org/x/time/rate"
 )
 
 // Update processes user input and updates the model state
@@ -14,9 +17,20 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		return m.handleWindowSizeMsg(msg)
 	case tickMsg:
+		m.Stats = m.Stats.sample(time.Time(msg), m.Publisher.Completed)
 		return m, tickCmd()
+	case startMsg:
+		return m.dispatch()
 	case publishResultMsg:
 		return m.handlePublishResultMsg(msg)
+	case scheduleMsg:
+		return m.handleScheduleMsg(msg)
+	case connectionLostMsg:
+		return m.handleConnectionLostMsg(msg)
+	case reconnectedMsg:
+		return m.handleReconnectedMsg(msg)
+	case writtenMsg:
+		return m.handleWrittenMsg(msg)
 	}
 
 	return m, nil
@@ -24,19 +38,123 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 // handleKeyMsg processes keyboard input
 func (m Model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.UI.Confirming {
+		return m.handleConfirmKey(msg)
+	}
+	if m.UI.Jumping {
+		return m.handleJumpKey(msg)
+	}
+	if m.UI.Writing {
+		return m.handleWriteKey(msg)
+	}
+	if m.IsComplete() && !m.UI.ErrorLog {
+		return m.handleSummaryKey(msg)
+	}
+
 	switch msg.String() {
 	case "ctrl+c", "q":
 		return m, tea.Quit
 	case " ":
 		return m.togglePause()
+	case "p":
+		return m.togglePreview()
+	case "e":
+		return m.toggleErrorLog()
+	case "up", "k":
+		if m.UI.ErrorLog {
+			return m.moveErrorCursor(-1)
+		}
+		return m.scrollPreview(-1)
+	case "down", "j":
+		if m.UI.ErrorLog {
+			return m.moveErrorCursor(1)
+		}
+		return m.scrollPreview(1)
+	case "r":
+		if m.UI.ErrorLog {
+			return m.retrySelected()
+		}
+	case "R":
+		if m.UI.ErrorLog {
+			return m.retryAll()
+		}
+	case "esc":
+		if m.UI.ErrorLog {
+			return m.toggleErrorLog()
+		}
+	case "g":
+		return m.startJump()
+	case "s":
+		return m.skip()
+	case "n":
+		return m.step()
+	case "x":
+		return m.toggleExclude()
 	case "+":
+		switch {
+		case m.Timing.Enabled:
+			return m.increaseSpeed()
+		case m.Publisher.Limiter != nil:
+			return m.increaseRate()
+		}
 		return m.increaseDelay()
 	case "-":
+		switch {
+		case m.Timing.Enabled:
+			return m.decreaseSpeed()
+		case m.Publisher.Limiter != nil:
+			return m.decreaseRate()
+		}
 		return m.decreaseDelay()
 	}
 	return m, nil
 }
 
+// handleConfirmKey accepts or declines the pre-flight summary. A protected
+// target is only accepted once its queue name has been typed.
+func (m Model) handleConfirmKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.Publisher.Protected {
+		return m.handleProtectedKey(msg)
+	}
+
+	switch msg.String() {
+	case "enter", "y":
+		return m.confirm()
+	case "ctrl+c", "q", "n", "esc":
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// handleProtectedKey edits the typed queue name, starting once it matches
+func (m Model) handleProtectedKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		if m.UI.ConfirmInput.Value() != m.Publisher.QueueName {
+			m.UI.ConfirmError = "The name does not match the target queue"
+			return m, nil
+		}
+		return m.confirm()
+	case "ctrl+c", "esc":
+		return m, tea.Quit
+	}
+
+	var cmd tea.Cmd
+	m.UI.ConfirmInput, cmd = m.UI.ConfirmInput.Update(msg)
+	m.UI.ConfirmError = ""
+	return m, cmd
+}
+
+// confirm leaves the confirmation screen and starts publishing
+func (m Model) confirm() (tea.Model, tea.Cmd) {
+	m.UI.Confirming = false
+	m.UI.ConfirmInput.Blur()
+	// The clock starts with the first message, not when the summary was shown
+	m.Stats.StartTime = time.Now()
+	m.Timing.Anchor = time.Now()
+	return m.dispatch()
+}
+
 // handleWindowSizeMsg updates the model when window size changes
 func (m Model) handleWindowSizeMsg(msg tea.WindowSizeMsg) (tea.Model, tea.Cmd) {
 	m.UI.Width = msg.Width
@@ -48,27 +166,75 @@ func (m Model) handleWindowSizeMsg(msg tea.WindowSizeMsg) (tea.Model, tea.Cmd) {
 
 // handlePublishResultMsg processes the result of publishing a message
 func (m Model) handlePublishResultMsg(msg publishResultMsg) (tea.Model, tea.Cmd) {
-	if m.Publisher.CurrentIndex < m.Publisher.TotalMessages {
-		m.Publisher.CurrentIndex++
-		if msg.success {
-			m.Stats.SuccessCount++
-		} else {
-			m.Stats.ErrorCount++
-			if msg.err != nil {
-				m.Publisher.LastError = msg.err.Error()
-			}
+	if msg.cancelled {
+		m = m.requeue(msg.delivery)
+		return m.dispatch()
+	}
+
+	// Messages that failed because the connection went away are not counted;
+	// they are published again once failed over
+	if !msg.success && m.Publisher.Connector != nil && publisher.IsConnectionError(msg.err) {
+		m = m.requeue(msg.delivery)
+		// A session already replaced was failed over from; the current one is fine
+		if msg.generation != m.Publisher.Generation {
+			return m.dispatch()
 		}
+		return m.failover(msg.err)
 	}
 
-	if m.IsComplete() {
+	m = m.recordResult(msg.delivery.lane, msg.delivery, msg.success, msg.err)
+
+	var cmd tea.Cmd
+	if !m.IsComplete() {
+		m, cmd = m.dispatch()
+	}
+	// Dispatching may pass over excluded messages up to the end
+	if m.IsComplete() && m.Stats.FinishTime.IsZero() {
+		m.Stats.FinishTime = time.Now()
+	}
+	return m, cmd
+}
+
+// handleScheduleMsg re-checks whether the next message is due under original timing
+func (m Model) handleScheduleMsg(msg scheduleMsg) (tea.Model, tea.Cmd) {
+	m.Timing.Waiting = false
+	return m.dispatch()
+}
+
+// handleConnectionLostMsg starts failing over when the current session drops
+func (m Model) handleConnectionLostMsg(msg connectionLostMsg) (tea.Model, tea.Cmd) {
+	if msg.generation != m.Publisher.Generation || m.Publisher.Connector == nil {
 		return m, nil
 	}
+	return m.failover(msg.err)
+}
 
-	if !m.UI.IsPaused {
-		return m, publishMessageCmd(m)
+// handleReconnectedMsg installs the new session, or tries again later
+func (m Model) handleReconnectedMsg(msg reconnectedMsg) (tea.Model, tea.Cmd) {
+	if msg.err != nil {
+		m.Publisher.LastError = fmt.Sprintf("reconnect failed, retrying in %s: %v", reconnectBackoff, msg.err)
+		return m, reconnectCmd(m.Publisher.Connector, nil, reconnectBackoff)
 	}
 
-	return m, nil
+	m.Publisher.Conn = msg.conn
+	m.Publisher.Generation++
+	m.Publisher.Reconnecting = false
+	m.Publisher.Failovers++
+
+	m, cmd := m.dispatch()
+	return m, tea.Batch(cmd, watchConnectionCmd(m.Publisher.Conn, m.Publisher.Generation))
+}
+
+// failover holds publishing and reconnects, moving on to the next node
+func (m Model) failover(cause error) (tea.Model, tea.Cmd) {
+	if m.Publisher.Reconnecting {
+		return m, nil
+	}
+	m.Publisher.Reconnecting = true
+	if cause != nil {
+		m.Publisher.LastError = fmt.Sprintf("connection lost, failing over: %v", cause)
+	}
+	return m, reconnectCmd(m.Publisher.Connector, m.Publisher.Conn, 0)
 }
 
 // togglePause toggles the pause state
@@ -78,11 +244,21 @@ func (m Model) togglePause() (tea.Model, tea.Cmd) {
 	if m.UI.IsPaused {
 		// When pausing, record the time pause started
 		m.Stats.PauseStartTime = time.Now()
+		m.cancelWaits()
+		if m.Timing.Enabled {
+			m = m.reanchor()
+		}
 		return m, nil
 	} else {
 		// When resuming, add the paused duration to the total paused time
 		m.Stats.TotalPausedTime += time.Since(m.Stats.PauseStartTime)
-		return m, publishMessageCmd(m)
+		// The preview follows the cursor again, and steps not taken yet are
+		// taken anyway
+		m.UI.PreviewOffset = 0
+		m.Publisher.Steps = 0
+		// Pick the original timing schedule up where the pause left it
+		m.Timing.Anchor = time.Now()
+		return m.dispatch()
 	}
 }
 
@@ -102,3 +278,82 @@ func (m Model) decreaseDelay() (tea.Model, tea.Cmd) {
 	}
 	return m, nil
 }
+
+// speedSteps are the playback multipliers offered when adjusting speed live
+var speedSteps = []float64{0.1, 0.25, 0.5, 1, 2, 5, 10, 20, 50, 100, 1000}
+
+// increaseSpeed moves original timing playback to the next faster step
+func (m Model) increaseSpeed() (tea.Model, tea.Cmd) {
+	if !m.UI.IsPaused {
+		m = m.reanchor()
+	}
+	for _, step := range speedSteps {
+		if step > m.Timing.Speed {
+			m.Timing.Speed = step
+			break
+		}
+	}
+	return m, nil
+}
+
+// decreaseSpeed moves original timing playback to the next slower step
+func (m Model) decreaseSpeed() (tea.Model, tea.Cmd) {
+	if !m.UI.IsPaused {
+		m = m.reanchor()
+	}
+	for i := len(speedSteps) - 1; i >= 0; i-- {
+		if speedSteps[i] < m.Timing.Speed {
+			m.Timing.Speed = speedSteps[i]
+			break
+		}
+	}
+	return m, nil
+}
+
+// minRate is the lowest target rate (msg/sec) decreaseRate goes down to
+const minRate = 0.1
+
+// rateSteps are the target rates (msg/sec) offered when adjusting the rate live
+var rateSteps = []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000, 2000, 5000, 10000, 20000, 50000}
+
+// increaseRate raises the target rate to the next step. Below the first
+// step it doubles, the way decreaseRate halves it.
+func (m Model) increaseRate() (tea.Model, tea.Cmd) {
+	current := float64(m.Publisher.Limiter.Limit())
+	if current < rateSteps[0] {
+		return m.setRate(min(current*2, rateSteps[0]))
+	}
+	for _, step := range rateSteps {
+		if step > current {
+			return m.setRate(step)
+		}
+	}
+	return m, nil
+}
+
+// decreaseRate lowers the target rate to the previous step, or halves it
+// at or below the first step, e.g. when started with --rate 30/m, down to
+// minRate
+func (m Model) decreaseRate() (tea.Model, tea.Cmd) {
+	current := float64(m.Publisher.Limiter.Limit())
+	if current <= rateSteps[0] {
+		if current <= minRate {
+			return m, nil
+		}
+		return m.setRate(max(current/2, minRate))
+	}
+	for i := len(rateSteps) - 1; i >= 0; i-- {
+		if rateSteps[i] < current {
+			return m.setRate(rateSteps[i])
+		}
+	}
+	return m, nil
+}
+
+// setRate changes the target rate, cutting short the waits reserved under
+// the old one so that it applies at once
+func (m Model) setRate(perSec float64) (tea.Model, tea.Cmd) {
+	m.Publisher.Limiter.SetLimit(rate.Limit(perSec))
+	m.cancelWaits()
+	return m, nil
+}
@@ -1,9 +1,11 @@
 package ui
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/marianozunino/go-publish/internal/publisher"
 	"github.com/marianozunino/go-publish/internal/ui/components"
 )
 
@@ -22,8 +24,11 @@ func (m Model) View() string {
 	// Calculate elapsed time correctly, accounting for pauses
 	elapsed := m.calculateElapsedTime()
 
-	// Calculate progress
-	progress := float64(m.Publisher.CurrentIndex) / float64(m.Publisher.TotalMessages)
+	// Calculate progress (an unbounded run has no meaningful total)
+	var progress float64
+	if !m.Publisher.Unbounded {
+		progress = float64(m.position()) / float64(m.Publisher.TotalMessages)
+	}
 
 	// Calculate messages per second
 	msgPerSec := m.calculateMessageRate(elapsed)
@@ -32,23 +37,43 @@ func (m Model) View() string {
 	eta := m.calculateETA(msgPerSec)
 
 	// Build the view
-	s := m.UI.Styles.Title.Render("🐰 RabbitMQ Message Resender 🐰")
+	s := m.UI.Styles.Title.Render(title(m.Publisher.Conn.Target().URI))
 
 	// Queue information box
 	s += components.RenderQueueInfo(
+		m.Publisher.Conn.Target().URI,
+		m.Publisher.Reconnecting,
+		m.Publisher.Failovers,
 		m.Publisher.QueueName,
+		len(m.Publisher.Lanes),
+		m.Publisher.Conn.Target().Connections,
+		m.Publisher.OrderKey != nil,
 		m.Publisher.InsecureTLS,
 		m.getStylesMap(),
 	)
 
+	// Nothing is sent until the pre-flight summary is accepted
+	if m.UI.Confirming {
+		return s + m.preflightView()
+	}
+
+	// Once complete, the summary offers to deal with the failures
+	if m.IsComplete() && !m.UI.ErrorLog {
+		return s + m.summaryView()
+	}
+
 	// Progress section
 	// Adapt progress bar to terminal width
 	m.UI.Progress.Width = contentWidth - 8
-	progressBar := m.UI.Progress.ViewAs(progress)
+	progressBar := ""
+	if !m.Publisher.Unbounded {
+		progressBar = m.UI.Progress.ViewAs(progress)
+	}
 
 	s += components.RenderProgressSection(
-		m.Publisher.CurrentIndex,
+		m.position(),
 		m.Publisher.TotalMessages,
+		m.Publisher.RetryPass,
 		progressBar,
 		m.getStylesMap(),
 	)
@@ -58,27 +83,106 @@ func (m Model) View() string {
 		m.UI.IsPaused,
 		m.Stats.SuccessCount,
 		m.Stats.ErrorCount,
-		m.Publisher.Delay,
+		m.Stats.SkippedCount,
+		m.pacingLabel(),
 		msgPerSec,
+		m.Stats.recentRate(),
 		eta,
 		elapsed,
 		contentWidth,
 		m.getStylesMap(),
 	)
 
-	// Last error if any
-	s += components.RenderErrorBox(m.Publisher.LastError, m.getStylesMap())
+	if m.UI.ErrorLog {
+		// Every failure kept, in place of the lanes, preview and last error
+		s += m.errorLogView(contentWidth - 10)
+	} else {
+		// Per-lane progress when publishing concurrently
+		s += components.RenderLanesSection(m.laneProgress(), contentWidth/3, m.getStylesMap())
+
+		// The messages around the cursor, when toggled on
+		s += m.previewView(contentWidth - 10)
 
-	// Controls box at bottom
-	s += components.RenderControlsBox(m.getStylesMap())
+		// Last error if any
+		s += components.RenderErrorBox(m.Publisher.LastError, len(m.Publisher.Errors), m.getStylesMap())
+	}
+
+	// Controls box at bottom, or the prompt for the message to jump to
+	if m.UI.Jumping {
+		return s + components.RenderJumpBox(m.UI.JumpInput.View(), m.UI.JumpError, m.getStylesMap())
+	}
+	if m.UI.ErrorLog {
+		return s + components.RenderErrorLogControls(m.getStylesMap())
+	}
+	s += components.RenderControlsBox(m.Timing.Enabled, m.UI.Preview, m.UI.IsPaused, m.getStylesMap())
 
 	return s
 }
 
+// preflightView renders the pre-flight summary and confirmation prompt
+func (m Model) preflightView() string {
+	var s string
+	if p := m.Publisher.Preflight; p != nil {
+		s += components.RenderPreflightBox(
+			p.Exists,
+			p.Messages,
+			p.Consumers,
+			p.Arguments,
+			p.Policy,
+			p.PolicyDefinition,
+			p.Unknown,
+			p.Warnings,
+			m.Publisher.TotalMessages,
+			m.getStylesMap(),
+		)
+	}
+
+	if !m.Publisher.Protected {
+		return s + components.RenderConfirmBox(m.getStylesMap())
+	}
+	return s + components.RenderProtectedBox(
+		m.Publisher.Conn.Target().Host,
+		m.Publisher.Conn.Target().Vhost,
+		m.Publisher.QueueName,
+		m.Publisher.TotalMessages,
+		m.UI.ConfirmInput.View(),
+		m.UI.ConfirmError,
+		m.getStylesMap(),
+	)
+}
+
+// laneProgress summarizes the lanes for rendering
+func (m Model) laneProgress() []components.LaneProgress {
+	lanes := make([]components.LaneProgress, len(m.Publisher.Lanes))
+	for i, lane := range m.Publisher.Lanes {
+		lanes[i] = components.LaneProgress{
+			Busy:   lane.Busy,
+			Done:   lane.Done,
+			Queued: len(lane.Queue),
+		}
+	}
+	return lanes
+}
+
+// pacingLabel describes how messages are currently being spaced out
+func (m Model) pacingLabel() string {
+	if m.Timing.Enabled {
+		return fmt.Sprintf("⏱️  Timing: original @ %gx", m.Timing.Speed)
+	}
+	if m.Publisher.Limiter != nil {
+		return fmt.Sprintf("🎯 Target: %g msg/sec (burst %d)",
+			float64(m.Publisher.Limiter.Limit()), m.Publisher.Limiter.Burst())
+	}
+	return fmt.Sprintf("⏱️  Delay: %s", m.Publisher.Delay.Round(time.Millisecond))
+}
+
 // calculateElapsedTime calculates the elapsed time accounting for pauses
 func (m Model) calculateElapsedTime() time.Duration {
 	var elapsed time.Duration
-	if m.UI.IsPaused {
+	if !m.Stats.FinishTime.IsZero() {
+		// Once complete, the clock stops
+		elapsed = m.Stats.FinishTime.Sub(m.Stats.StartTime) - m.Stats.TotalPausedTime
+	} else if m.UI.IsPaused {
 		// If currently paused, only count time up to when pause started
 		elapsed = m.Stats.PauseStartTime.Sub(m.Stats.StartTime) - m.Stats.TotalPausedTime
 	} else {
@@ -91,17 +195,48 @@ func (m Model) calculateElapsedTime() time.Duration {
 // calculateMessageRate calculates the messages per second rate
 func (m Model) calculateMessageRate(elapsed time.Duration) float64 {
 	var msgPerSec float64
-	if elapsed > 0 && m.Publisher.CurrentIndex > 0 {
-		msgPerSec = float64(m.Publisher.CurrentIndex) / elapsed.Seconds()
+	if elapsed > 0 && m.Publisher.Completed > 0 {
+		msgPerSec = float64(m.Publisher.Completed) / elapsed.Seconds()
 	}
 	return msgPerSec
 }
 
+// recentRateWindow is how far back the current (as opposed to average) rate looks
+const recentRateWindow = 5 * time.Second
+
+// sample records the processed count at t, dropping samples outside the window
+func (s Statistics) sample(t time.Time, count int) Statistics {
+	samples := append(s.RateSamples, rateSample{at: t, count: count})
+	start := 0
+	for start < len(samples)-1 && t.Sub(samples[start].at) > recentRateWindow {
+		start++
+	}
+	s.RateSamples = samples[start:]
+	return s
+}
+
+// recentRate returns the messages per second achieved over the recent window
+func (s Statistics) recentRate() float64 {
+	if len(s.RateSamples) < 2 {
+		return 0
+	}
+	first, last := s.RateSamples[0], s.RateSamples[len(s.RateSamples)-1]
+	span := last.at.Sub(first.at).Seconds()
+	if span <= 0 {
+		return 0
+	}
+	return float64(last.count-first.count) / span
+}
+
 // calculateETA calculates the estimated time remaining
 func (m Model) calculateETA(msgPerSec float64) time.Duration {
 	var eta time.Duration
-	if msgPerSec > 0 && m.Publisher.CurrentIndex < m.Publisher.TotalMessages {
-		remainingMsgs := m.Publisher.TotalMessages - m.Publisher.CurrentIndex
+	if m.Publisher.Unbounded {
+		// Negative ETA means there is no end in sight
+		return -1
+	}
+	if msgPerSec > 0 && m.position() < m.Publisher.TotalMessages {
+		remainingMsgs := m.Publisher.TotalMessages - m.position()
 		etaSeconds := float64(remainingMsgs) / msgPerSec
 		eta = time.Duration(etaSeconds) * time.Second
 	}
@@ -124,3 +259,26 @@ func (m Model) getStylesMap() map[string]lipgloss.Style {
 		"controlsBox": m.UI.Styles.ControlsBox,
 	}
 }
+
+// title names the backend messages are resent to, after the scheme of its URI
+func title(uri string) string {
+	scheme, _ := publisher.Scheme(uri)
+	switch scheme {
+	case "amqp", "amqps":
+		return "🐰 RabbitMQ Message Resender 🐰"
+	case "amqp10", "amqp10s":
+		return "📨 AMQP 1.0 Message Resender 📨"
+	case "kafka", "kafkas":
+		return "📨 Kafka Message Resender 📨"
+	case "nats":
+		return "📨 NATS JetStream Message Resender 📨"
+	case "redis", "rediss":
+		return "📨 Redis Streams Message Resender 📨"
+	case "mqtt", "mqtts":
+		return "📨 MQTT Message Resender 📨"
+	case "http", "https":
+		return "📨 HTTP Message Resender 📨"
+	default:
+		return "📨 Message Resender 📨"
+	}
+}
@@ -0,0 +1,300 @@
+// Package webhook is the HTTP publishing backend, posting each message to an
+// ingestion endpoint
+package webhook
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/marianozunino/go-publish/internal/models"
+	"github.com/marianozunino/go-publish/internal/publisher"
+	"github.com/marianozunino/go-publish/internal/redact"
+	"github.com/marianozunino/go-publish/internal/tlsconfig"
+)
+
+const (
+	// requestTimeout bounds a single request, including reading the response
+	requestTimeout = 30 * time.Second
+	// retryBackoff is the wait before the first retry; it doubles on each one
+	retryBackoff = 500 * time.Millisecond
+	// maxRetryWait caps both the backoff and the wait a Retry-After asks for
+	maxRetryWait = time.Minute
+	// maxErrorBody is how much of an error response is quoted
+	maxErrorBody = 200
+)
+
+// Options describes the endpoint and how messages are posted to it
+type Options struct {
+	URL          string // http://[user:pass@]host/path or https://
+	TLS          tlsconfig.Options
+	UserAgent    string
+	Headers      map[string]string // message header name to HTTP header name
+	HeaderPrefix string            // prepended to the names of headers not in Headers
+	Retries      int               // retries of a request failing with 429, 5xx or a network error
+	Workers      int               // requests in flight at once
+}
+
+// Dialer creates the HTTP client. There is no connection to keep, so it
+// never fails over.
+type Dialer struct {
+	opts     Options
+	endpoint string // the URL without credentials
+	user     *url.Userinfo
+}
+
+// NewDialer validates the options and returns a Dialer for them
+func NewDialer(opts Options) (*Dialer, error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid HTTP URL %q", redact.URI(opts.URL))
+	}
+	if u.Scheme == "http" && opts.TLS.IsSet() {
+		return nil, fmt.Errorf("TLS options require an https:// URL")
+	}
+	if opts.Retries < 0 {
+		return nil, fmt.Errorf("invalid number of retries %d", opts.Retries)
+	}
+	for name, header := range opts.Headers {
+		if !validHeaderName(header) {
+			return nil, fmt.Errorf("invalid HTTP header name %q for header %q", header, name)
+		}
+	}
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+
+	user := u.User
+	u.User = nil
+	return &Dialer{opts: opts, endpoint: u.String(), user: user}, nil
+}
+
+// Connect creates a client for the endpoint. Nothing is sent until the first
+// message, as an ingestion endpoint may accept nothing but POSTs.
+func (d *Dialer) Connect() (publisher.Publisher, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = d.opts.Workers
+	if strings.HasPrefix(d.endpoint, "https://") {
+		tlsCfg, err := d.opts.TLS.Build()
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsCfg
+	}
+
+	return &Poster{
+		dialer: d,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   requestTimeout,
+			// A redirected POST may turn into a GET; report it instead
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}, nil
+}
+
+// Poster posts messages to the endpoint
+type Poster struct {
+	dialer *Dialer
+	client *http.Client
+}
+
+// Lanes returns the number of workers; the client is safe for concurrent use
+func (p *Poster) Lanes() int {
+	return p.dialer.opts.Workers
+}
+
+// Publish posts msg, retrying on 429, 5xx and network errors. Only a 2xx
+// response counts as published.
+func (p *Poster) Publish(lane int, msg models.RawMessage) error {
+	retries := p.dialer.opts.Retries
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := p.post(msg)
+		if err == nil || retryAfter < 0 {
+			return err
+		}
+		if attempt == retries {
+			if retries > 0 {
+				err = fmt.Errorf("%w (gave up after %d retries)", err, retries)
+			}
+			return err
+		}
+
+		wait := retryAfter
+		if wait == 0 {
+			// Double up to the cap, as shifting alone overflows after enough retries
+			wait = retryBackoff
+			for i := 0; i < attempt && wait < maxRetryWait; i++ {
+				wait *= 2
+			}
+		}
+		if wait > maxRetryWait {
+			wait = maxRetryWait
+		}
+		time.Sleep(wait)
+	}
+}
+
+// post sends one request. It returns when to retry: after the given wait, at
+// once with the backoff for 0, or never when negative.
+func (p *Poster) post(msg models.RawMessage) (time.Duration, error) {
+	req, err := p.request(msg)
+	if err != nil {
+		return -1, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		// The error quotes the URL, which no longer carries the password
+		if !retryable(err) {
+			return -1, err
+		}
+		return 0, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return 0, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return retryAfter(resp.Header.Get("Retry-After")), statusError(resp, body)
+	default:
+		return -1, statusError(resp, body)
+	}
+}
+
+// request builds the POST of a message: the payload is the body, the content
+// type becomes Content-Type and message headers become HTTP headers
+func (p *Poster) request(msg models.RawMessage) (*http.Request, error) {
+	opts := p.dialer.opts
+	req, err := http.NewRequest(http.MethodPost, p.dialer.endpoint, bytes.NewReader([]byte(msg.Payload)))
+	if err != nil {
+		return nil, err
+	}
+
+	if user := p.dialer.user; user != nil {
+		password, _ := user.Password()
+		req.SetBasicAuth(user.Username(), password)
+	}
+	if opts.UserAgent != "" {
+		req.Header.Set("User-Agent", opts.UserAgent)
+	}
+	if msg.Properties.ContentType != "" {
+		req.Header.Set("Content-Type", msg.Properties.ContentType)
+	}
+
+	for name, value := range msg.Properties.Headers {
+		header, mapped := opts.Headers[name]
+		if !mapped {
+			header = opts.HeaderPrefix + name
+		}
+		// Names HTTP cannot carry are left out rather than failing the message
+		if validHeaderName(header) {
+			req.Header.Add(header, headerValue(publisher.HeaderString(value)))
+		}
+	}
+	return req, nil
+}
+
+// headerValue replaces the control characters HTTP header values cannot
+// carry, such as line breaks, with spaces
+func headerValue(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r != '\t' && (r < ' ' || r == 0x7f) {
+			return ' '
+		}
+		return r
+	}, value)
+}
+
+// retryable reports whether a request that got no response may succeed when
+// sent again: the network failed, rather than the request being invalid
+func retryable(err error) bool {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	// Every error of the client is a *url.Error, itself a net.Error
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// Confirm returns nil: Publish already waited for the response
+func (p *Poster) Confirm(lane int) error {
+	return nil
+}
+
+// Health returns a channel that never fires, as there is no connection to lose
+func (p *Poster) Health() <-chan error {
+	return make(chan error)
+}
+
+// Target describes the endpoint
+func (p *Poster) Target() publisher.Target {
+	u, _ := url.Parse(p.dialer.endpoint)
+	return publisher.Target{
+		URI:         p.dialer.endpoint,
+		Host:        u.Host,
+		Vhost:       u.Path,
+		Connections: 1,
+	}
+}
+
+// Close releases idle connections
+func (p *Poster) Close() error {
+	p.client.CloseIdleConnections()
+	return nil
+}
+
+// statusError describes an unsuccessful response, quoting the start of its body
+func statusError(resp *http.Response, body []byte) error {
+	msg := strings.Join(strings.Fields(string(body)), " ")
+	if msg == "" {
+		return fmt.Errorf("HTTP %s", resp.Status)
+	}
+	return fmt.Errorf("HTTP %s: %s", resp.Status, msg)
+}
+
+// retryAfter parses a Retry-After header, given in seconds or as a date.
+// It returns 0 when the header is missing or invalid.
+func retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(at); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// validHeaderName reports whether name is a valid HTTP header field name
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if c >= 0x7f || c <= ' ' || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, c) {
+			return false
+		}
+	}
+	return true
+}